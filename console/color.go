@@ -0,0 +1,153 @@
+// color.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package console
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Console color depth
+type ColorDepth int
+
+const (
+	// Auto-detect color depth (using the COLORTERM and TERM environment variables)
+	ColorDepthAuto ColorDepth = 0
+	// Basic 16 colors
+	ColorDepth16 ColorDepth = 16
+	// 256 colors
+	ColorDepth256 ColorDepth = 256
+	// 24-bit true colors
+	ColorDepthTrue ColorDepth = 1 << 24
+)
+
+// Color256 gets the SGR parameters for the given 256-color palette index as foreground color.
+func Color256(index uint8) string {
+	return "38;5;" + strconv.Itoa(int(index))
+}
+
+// TrueColor gets the SGR parameters for the given 24-bit color as foreground color.
+func TrueColor(r, g, b uint8) string {
+	return "38;2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+}
+
+func detectColorDepth() ColorDepth {
+	colorTerm := strings.ToLower(os.Getenv("COLORTERM"))
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return ColorDepthTrue
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return ColorDepth256
+	}
+	return ColorDepth16
+}
+
+func downgradeColor(code string, depth ColorDepth) string {
+	if code == "" || depth >= ColorDepthTrue {
+		return code
+	}
+	params := strings.Split(code, ";")
+	downgraded := make([]string, 0, len(params))
+	for i := 0; i < len(params); i++ {
+		if (params[i] != "38" && params[i] != "48") || i+1 >= len(params) {
+			downgraded = append(downgraded, params[i])
+			continue
+		}
+		background := params[i] == "48"
+		switch {
+		case params[i+1] == "5" && i+2 < len(params):
+			index, _ := strconv.Atoi(params[i+2])
+			if depth >= ColorDepth256 {
+				downgraded = append(downgraded, params[i:i+3]...)
+			} else {
+				downgraded = append(downgraded, basicColor(rgb256(index), background))
+			}
+			i += 2
+		case params[i+1] == "2" && i+4 < len(params):
+			r, _ := strconv.Atoi(params[i+2])
+			g, _ := strconv.Atoi(params[i+3])
+			b, _ := strconv.Atoi(params[i+4])
+			if depth >= ColorDepth256 {
+				downgraded = append(downgraded, params[i], "5", strconv.Itoa(index256(r, g, b)))
+			} else {
+				downgraded = append(downgraded, basicColor([3]int{r, g, b}, background))
+			}
+			i += 4
+		default:
+			downgraded = append(downgraded, params[i])
+		}
+	}
+	return strings.Join(downgraded, ";")
+}
+
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+func rgb256(index int) [3]int {
+	switch {
+	case index < 16:
+		basic := [16][3]int{
+			{0, 0, 0}, {128, 0, 0}, {0, 128, 0}, {128, 128, 0}, {0, 0, 128}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192},
+			{128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {0, 0, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+		}
+		return basic[max(index, 0)]
+	case index < 232:
+		index -= 16
+		return [3]int{cubeLevels[index/36], cubeLevels[(index/6)%6], cubeLevels[index%6]}
+	}
+	gray := 8 + 10*(min(index, 255)-232)
+	return [3]int{gray, gray, gray}
+}
+
+func index256(r, g, b int) int {
+	if r == g && g == b {
+		switch {
+		case r < 8:
+			return 16
+		case r > 248:
+			return 231
+		}
+		return 232 + (r-8)*24/247
+	}
+	cube := func(c int) int {
+		return (c*5 + 127) / 255
+	}
+	return 16 + 36*cube(r) + 6*cube(g) + cube(b)
+}
+
+func basicColor(rgb [3]int, background bool) string {
+	base := 30
+	if background {
+		base = 40
+	}
+	index := 0
+	for channel, value := range rgb {
+		if value >= 128 {
+			index |= 1 << channel
+		}
+	}
+	if index == 0 && max(rgb[0], rgb[1], rgb[2]) >= 64 {
+		return strconv.Itoa(base + 60)
+	}
+	if index == 7 && min(rgb[0], rgb[1], rgb[2]) >= 224 {
+		return strconv.Itoa(base + 67)
+	}
+	return strconv.Itoa(base + index)
+}
+
+func detectHyperlinks() bool {
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "vscode", "WezTerm", "ghostty":
+		return true
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("DOMTERM") != "" {
+		return true
+	}
+	vteVersion, err := strconv.Atoi(os.Getenv("VTE_VERSION"))
+	return err == nil && vteVersion >= 5000
+}
@@ -11,17 +11,20 @@ package console
 import (
 	"io"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
+	"golang.org/x/term"
 )
 
 // Console color mode
 type Color int
 
 const (
-	// Auto-detect coloring
+	// Auto-detect coloring (honoring the NO_COLOR, CLICOLOR, CLICOLOR_FORCE and TERM environment variables)
 	ColorAuto Color = -1
 	// Force coloring off
 	ColorOff Color = 0
@@ -34,19 +37,349 @@ func NewDefaultWriter() io.Writer {
 	return NewWriter(os.Stderr, ColorOff, time.RFC3339)
 }
 
+// Option customizes the console writer created by [NewWriter].
+type Option func(*writerOptions)
+
+type writerOptions struct {
+	utc        bool
+	theme      *Theme
+	colorDepth ColorDepth
+	levelNames map[zerolog.Level]string
+	sourcePath SourcePath
+	function   bool
+	layout     string
+	multiline  bool
+	fieldLines bool
+	prettyJSON bool
+	hyperlinks string
+	wrapWidth  int
+	alignKeys  bool
+	highlights []HighlightRule
+	iconMode   LevelIconMode
+	levelIcons map[zerolog.Level]string
+
+	timestampMode TimestampMode
+	clock         func() time.Time
+	deterministic bool
+}
+
+// WithUTC sets whether timestamps are displayed in UTC (instead of local time).
+func WithUTC(utc bool) Option {
+	return func(options *writerOptions) {
+		options.utc = utc
+	}
+}
+
+// WithTheme sets the theme to use for colorizing the output.
+func WithTheme(theme *Theme) Option {
+	return func(options *writerOptions) {
+		options.theme = theme
+	}
+}
+
+// WithColorDepth sets the color depth supported by the console.
+func WithColorDepth(colorDepth ColorDepth) Option {
+	return func(options *writerOptions) {
+		options.colorDepth = colorDepth
+	}
+}
+
+// WithLevelNames sets the names to display for the given levels (overriding the default names).
+func WithLevelNames(levelNames map[zerolog.Level]string) Option {
+	return func(options *writerOptions) {
+		options.levelNames = levelNames
+	}
+}
+
+// Caller source path display mode
+type SourcePath int
+
+const (
+	// Display source path relative to the current working directory
+	SourcePathRelative SourcePath = 0
+	// Display full source path
+	SourcePathFull SourcePath = 1
+	// Display source path relative to the containing module's root
+	SourcePathShort SourcePath = 2
+)
+
+// WithSourcePath sets how the caller's source path is displayed.
+func WithSourcePath(sourcePath SourcePath) Option {
+	return func(options *writerOptions) {
+		options.sourcePath = sourcePath
+	}
+}
+
+// WithFunctionName sets whether the caller's function name is displayed next to its source location.
+//
+// The function name is only available, if the caller field is rendered via [github.com/tdrn-org/go-log.CallerWithFunction].
+func WithFunctionName(function bool) Option {
+	return func(options *writerOptions) {
+		options.function = function
+	}
+}
+
+// WithLayout sets the layout of the log lines.
+//
+// The layout is a string containing the placeholders {time}, {level}, {caller} (or {source}), {message}
+// and {fields} (or {attrs}). A placeholder may carry a printf like width (e.g. {level:-5}) to pad the
+// corresponding part to a fixed width. An empty layout selects the default layout [DefaultLayout].
+func WithLayout(layout string) Option {
+	return func(options *writerOptions) {
+		if layout == "" {
+			layout = DefaultLayout
+		}
+		options.layout = layout
+	}
+}
+
+// WithMultiline sets whether multi-line messages (e.g. stack traces) are rendered with
+// their continuation lines indented below the log line.
+func WithMultiline(multiline bool) Option {
+	return func(options *writerOptions) {
+		options.multiline = multiline
+	}
+}
+
+// WithFieldLines sets whether fields are rendered one per line indented below the log line.
+func WithFieldLines(fieldLines bool) Option {
+	return func(options *writerOptions) {
+		options.fieldLines = fieldLines
+	}
+}
+
+// WithPrettyJSON sets whether structured field values (objects and arrays) are rendered as indented JSON.
+func WithPrettyJSON(prettyJSON bool) Option {
+	return func(options *writerOptions) {
+		options.prettyJSON = prettyJSON
+	}
+}
+
+const (
+	// FileHyperlinks links caller locations to the source file.
+	FileHyperlinks = "file://{path}"
+	// VSCodeHyperlinks links caller locations to the source file line in Visual Studio Code.
+	VSCodeHyperlinks = "vscode://file{path}:{line}"
+)
+
+// WithHyperlinks sets the URL template used to render caller locations as OSC 8 hyperlinks.
+//
+// The placeholders {path} and {line} are replaced with the caller's source path and line number. The path
+// is inserted as escaped URL path starting with a slash (e.g. "/C:/my%20src/main.go" for a Windows path).
+// Hyperlinks are only rendered if coloring is enabled and the terminal is known to support them.
+// An empty template disables hyperlinks.
+func WithHyperlinks(urlTemplate string) Option {
+	return func(options *writerOptions) {
+		options.hyperlinks = urlTemplate
+	}
+}
+
+// Timestamp display mode
+type TimestampMode int
+
+const (
+	// Display absolute timestamps
+	TimestampAbsolute TimestampMode = 0
+	// Display time elapsed since process start (e.g. +1.034s)
+	TimestampElapsed TimestampMode = 1
+	// Display time elapsed since the previous log record (e.g. +0.034s)
+	TimestampDelta TimestampMode = 2
+)
+
+// WithTimestampMode sets how timestamps are displayed.
+//
+// Elapsed and delta times are measured at the time a log record is written (see [WithClock]). They are
+// displayed even if the log records do not carry a timestamp.
+func WithTimestampMode(timestampMode TimestampMode) Option {
+	return func(options *writerOptions) {
+		options.timestampMode = timestampMode
+	}
+}
+
+// WithClock sets the function providing the current time for measuring elapsed and delta times
+// (see [WithTimestampMode]).
+//
+// By default the system clock is used and elapsed times are measured since process start. If a clock is
+// set, elapsed times are measured since the writer's creation.
+func WithClock(clock func() time.Time) Option {
+	return func(options *writerOptions) {
+		options.clock = clock
+	}
+}
+
+// WrapAuto selects the terminal width as the wrap width.
+const WrapAuto = -1
+
+// WithWrap sets the maximum line width after which fields are wrapped onto indented continuation lines.
+//
+// Setting the width to [WrapAuto] uses the terminal width (if the output is a terminal). Setting
+// the width to 0 disables wrapping.
+func WithWrap(width int) Option {
+	return func(options *writerOptions) {
+		options.wrapWidth = width
+	}
+}
+
+// WithAlignedKeys sets whether field keys are padded to a common width if fields are rendered
+// one per line (see [WithFieldLines]).
+func WithAlignedKeys(alignKeys bool) Option {
+	return func(options *writerOptions) {
+		options.alignKeys = alignKeys
+	}
+}
+
+// WithHighlights sets the rules for highlighting field values. For every field the first matching rule is applied.
+func WithHighlights(highlights ...HighlightRule) Option {
+	return func(options *writerOptions) {
+		options.highlights = highlights
+	}
+}
+
+// Level icon display mode
+type LevelIconMode int
+
+const (
+	// Display level names only
+	LevelIconsOff LevelIconMode = 0
+	// Display level icons in front of level names
+	LevelIconsPrefix LevelIconMode = 1
+	// Display level icons instead of level names
+	LevelIconsOnly LevelIconMode = 2
+)
+
+// DefaultLevelIcons defines the level icons used if no other icons are set.
+var DefaultLevelIcons = map[zerolog.Level]string{
+	zerolog.TraceLevel: "🔍",
+	zerolog.DebugLevel: "🐛",
+	zerolog.InfoLevel:  "ℹ",
+	zerolog.WarnLevel:  "⚠",
+	zerolog.ErrorLevel: "✖",
+	zerolog.FatalLevel: "✖",
+	zerolog.PanicLevel: "✖",
+}
+
+// WithLevelIcons sets whether and which icons are displayed for the log levels.
+// If icons is nil, [DefaultLevelIcons] are used.
+func WithLevelIcons(iconMode LevelIconMode, icons map[zerolog.Level]string) Option {
+	return func(options *writerOptions) {
+		options.iconMode = iconMode
+		options.levelIcons = icons
+	}
+}
+
+// WithDeterministic sets whether byte-stable output is generated (e.g. for golden file tests).
+//
+// In deterministic mode, coloring, hyperlinks and automatic wrapping are disabled, timestamps and caller
+// line numbers are suppressed and level names are padded to a fixed width.
+func WithDeterministic(deterministic bool) Option {
+	return func(options *writerOptions) {
+		options.deterministic = deterministic
+	}
+}
+
 // NewWriter creates a new [io.Writer] for console logging.
-func NewWriter(out *os.File, color Color, timeFormat string) io.Writer {
-	return &zerolog.ConsoleWriter{
-		Out:        out,
-		NoColor:    !colorFlag(out, color),
-		TimeFormat: timeFormat,
+func NewWriter(out *os.File, color Color, timeFormat string, options ...Option) io.Writer {
+	writerOptions := newWriterOptions(options)
+	format := newFormat(writerOptions, timeFormat, colorFlag(out, color), wrapWidth(out, writerOptions.wrapWidth))
+	if !format.noColor {
+		enableVirtualTerminal(out)
+	}
+	return &writer{
+		out:    colorable.NewColorable(out),
+		format: format,
+		layout: parseLayout(writerOptions.layout),
 	}
 }
 
+// NewPlainWriter creates a new [io.Writer] rendering log records like [NewWriter], but without any
+// terminal specific features (coloring, hyperlinks and automatic wrapping).
+//
+// The plain writer is intended for writing human-readable log files.
+func NewPlainWriter(out io.Writer, timeFormat string, options ...Option) io.Writer {
+	writerOptions := newWriterOptions(options)
+	format := newFormat(writerOptions, timeFormat, false, max(writerOptions.wrapWidth, 0))
+	return &writer{
+		out:    out,
+		format: format,
+		layout: parseLayout(writerOptions.layout),
+	}
+}
+
+func newWriterOptions(options []Option) *writerOptions {
+	writerOptions := &writerOptions{theme: DefaultTheme, layout: DefaultLayout}
+	for _, option := range options {
+		option(writerOptions)
+	}
+	if writerOptions.levelIcons == nil {
+		writerOptions.levelIcons = DefaultLevelIcons
+	}
+	return writerOptions
+}
+
+func newFormat(writerOptions *writerOptions, timeFormat string, color bool, wrapWidth int) *format {
+	if timeFormat == "" {
+		timeFormat = time.Kitchen
+	}
+	location := time.Local
+	if writerOptions.utc {
+		location = time.UTC
+	}
+	colorDepth := writerOptions.colorDepth
+	if colorDepth == ColorDepthAuto {
+		colorDepth = detectColorDepth()
+	}
+	format := &format{
+		theme:      writerOptions.theme.downgrade(colorDepth),
+		noColor:    !color,
+		timeFormat: timeFormat,
+		location:   location,
+		levelNames: writerOptions.levelNames,
+		sourcePath: writerOptions.sourcePath,
+		function:   writerOptions.function,
+		multiline:  writerOptions.multiline,
+		fieldLines: writerOptions.fieldLines,
+		prettyJSON: writerOptions.prettyJSON,
+		wrapWidth:  wrapWidth,
+		alignKeys:  writerOptions.alignKeys,
+		highlights: writerOptions.highlights,
+		iconMode:   writerOptions.iconMode,
+		levelIcons: writerOptions.levelIcons,
+
+		timestampMode: writerOptions.timestampMode,
+		clock:         writerOptions.clock,
+		start:         processStart,
+		deterministic: writerOptions.deterministic,
+	}
+	if format.clock == nil {
+		format.clock = time.Now
+	} else {
+		format.start = format.clock()
+	}
+	if format.deterministic {
+		format.noColor = true
+		format.wrapWidth = max(writerOptions.wrapWidth, 0)
+		format.levelWidth = format.maxLevelWidth()
+	} else if detectHyperlinks() {
+		format.hyperlinks = writerOptions.hyperlinks
+	}
+	return format
+}
+
+func wrapWidth(out *os.File, width int) int {
+	if width != WrapAuto {
+		return max(width, 0)
+	}
+	terminalWidth, _, err := term.GetSize(int(out.Fd()))
+	if err != nil {
+		return 0
+	}
+	return terminalWidth
+}
+
 func colorFlag(out *os.File, color Color) bool {
 	switch color {
 	case ColorAuto:
-		return isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd())
+		return autoColorFlag(out)
 	case ColorOff:
 		return false
 	case ColorOn:
@@ -55,18 +388,75 @@ func colorFlag(out *os.File, color Color) bool {
 	return false
 }
 
+func autoColorFlag(out *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	clicolorForce := os.Getenv("CLICOLOR_FORCE")
+	if clicolorForce != "" && clicolorForce != "0" {
+		return true
+	}
+	if os.Getenv("CLICOLOR") == "0" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd())
+}
+
 type YAMLConsoleConfig struct {
-	EnabledOption    bool   `yaml:"enabled"`
-	OutOption        string `yaml:"out"`
-	ColorOption      string `yaml:"color"`
-	TimeFormatOption string `yaml:"timeformat"`
+	EnabledOption       bool              `yaml:"enabled"`
+	OutOption           string            `yaml:"out"`
+	ColorOption         string            `yaml:"color"`
+	TimeFormatOption    string            `yaml:"timeformat"`
+	UTCOption           bool              `yaml:"utc"`
+	ThemeOption         string            `yaml:"theme"`
+	LevelNamesOption    map[string]string `yaml:"levelNames"`
+	SourcePathOption    string            `yaml:"sourcePath"`
+	FunctionNameOption  bool              `yaml:"functionName"`
+	LayoutOption        string            `yaml:"layout"`
+	MultilineOption     bool              `yaml:"multiline"`
+	FieldLinesOption    bool              `yaml:"fieldLines"`
+	PrettyJSONOption    bool              `yaml:"prettyJSON"`
+	HyperlinksOption    string            `yaml:"hyperlinks"`
+	TimestampOption     string            `yaml:"timestamp"`
+	WrapOption          string            `yaml:"wrap"`
+	AlignKeysOption     bool              `yaml:"alignKeys"`
+	HighlightsOption    []YAMLHighlight   `yaml:"highlights"`
+	LevelIconsOption    string            `yaml:"levelIcons"`
+	DeterministicOption bool              `yaml:"deterministic"`
+}
+
+type YAMLHighlight struct {
+	KeyOption   string   `yaml:"key"`
+	ColorOption string   `yaml:"color"`
+	AboveOption *float64 `yaml:"above"`
 }
 
 func (config *YAMLConsoleConfig) NewWriter() io.Writer {
 	if !config.EnabledOption {
 		return nil
 	}
-	return NewWriter(config.outOption(), config.colorOption(), config.timeFormatOption())
+	return NewWriter(config.outOption(), config.colorOption(), config.timeFormatOption(), config.options()...)
+}
+
+func (config *YAMLConsoleConfig) options() []Option {
+	return []Option{
+		WithUTC(config.UTCOption),
+		WithTheme(config.themeOption()),
+		WithLevelNames(config.levelNamesOption()),
+		WithSourcePath(config.sourcePathOption()),
+		WithFunctionName(config.FunctionNameOption),
+		WithLayout(config.layoutOption()),
+		WithMultiline(config.MultilineOption),
+		WithFieldLines(config.FieldLinesOption),
+		WithPrettyJSON(config.PrettyJSONOption),
+		WithHyperlinks(config.hyperlinksOption()),
+		WithTimestampMode(config.timestampOption()),
+		WithWrap(config.wrapOption()),
+		WithAlignedKeys(config.AlignKeysOption),
+		WithHighlights(config.highlightsOption()...),
+		WithLevelIcons(config.levelIconsOption(), nil),
+		WithDeterministic(config.DeterministicOption),
+	}
 }
 
 func (config *YAMLConsoleConfig) outOption() *os.File {
@@ -94,3 +484,107 @@ func (config *YAMLConsoleConfig) colorOption() Color {
 func (config *YAMLConsoleConfig) timeFormatOption() string {
 	return config.TimeFormatOption
 }
+
+func (config *YAMLConsoleConfig) themeOption() *Theme {
+	switch config.ThemeOption {
+	case "default":
+		return DefaultTheme
+	case "light":
+		return LightTheme
+	case "soft":
+		return SoftTheme
+	case "monochrome":
+		return MonochromeBoldTheme
+	}
+	return DefaultTheme
+}
+
+func (config *YAMLConsoleConfig) levelNamesOption() map[zerolog.Level]string {
+	levelNames := make(map[zerolog.Level]string, len(config.LevelNamesOption))
+	for levelString, levelName := range config.LevelNamesOption {
+		level, err := zerolog.ParseLevel(levelString)
+		if err != nil {
+			continue
+		}
+		levelNames[level] = levelName
+	}
+	return levelNames
+}
+
+func (config *YAMLConsoleConfig) sourcePathOption() SourcePath {
+	switch config.SourcePathOption {
+	case "relative":
+		return SourcePathRelative
+	case "full":
+		return SourcePathFull
+	case "short":
+		return SourcePathShort
+	}
+	return SourcePathRelative
+}
+
+func (config *YAMLConsoleConfig) layoutOption() string {
+	return config.LayoutOption
+}
+
+func (config *YAMLConsoleConfig) hyperlinksOption() string {
+	switch config.HyperlinksOption {
+	case "", "off":
+		return ""
+	case "file":
+		return FileHyperlinks
+	case "vscode":
+		return VSCodeHyperlinks
+	}
+	return config.HyperlinksOption
+}
+
+func (config *YAMLConsoleConfig) timestampOption() TimestampMode {
+	switch config.TimestampOption {
+	case "absolute":
+		return TimestampAbsolute
+	case "elapsed":
+		return TimestampElapsed
+	case "delta":
+		return TimestampDelta
+	}
+	return TimestampAbsolute
+}
+
+func (config *YAMLConsoleConfig) wrapOption() int {
+	switch config.WrapOption {
+	case "", "off":
+		return 0
+	case "auto":
+		return WrapAuto
+	}
+	width, err := strconv.Atoi(config.WrapOption)
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+func (config *YAMLConsoleConfig) highlightsOption() []HighlightRule {
+	highlights := make([]HighlightRule, 0, len(config.HighlightsOption))
+	for _, highlight := range config.HighlightsOption {
+		rule := HighlightRule{Key: highlight.KeyOption, Color: highlight.ColorOption}
+		if highlight.AboveOption != nil {
+			rule.Match = Above(*highlight.AboveOption)
+		}
+		highlights = append(highlights, rule)
+	}
+	return highlights
+}
+
+func (config *YAMLConsoleConfig) levelIconsOption() LevelIconMode {
+	switch config.LevelIconsOption {
+	case "off":
+		return LevelIconsOff
+	case "prefix":
+		return LevelIconsPrefix
+	case "only":
+		return LevelIconsOnly
+	}
+	return LevelIconsOff
+}
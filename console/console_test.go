@@ -0,0 +1,219 @@
+// console_test.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package console_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/tdrn-org/go-log/console"
+)
+
+const testRecord = `{"level":"warn","time":"2024-01-01T13:00:00+01:00","message":"message"}`
+
+func TestUTC(t *testing.T) {
+	output := writeRecord(t, testRecord, console.ColorOff, console.WithUTC(true))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN message\n", output)
+}
+
+func TestColorAuto(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "1")
+	output := writeRecord(t, testRecord, console.ColorAuto, console.WithUTC(true))
+	require.Equal(t, "\x1b[90m2024-01-01T12:00:00Z\x1b[0m \x1b[33mWRN\x1b[0m \x1b[1mmessage\x1b[0m\n", output)
+	t.Setenv("NO_COLOR", "1")
+	output = writeRecord(t, testRecord, console.ColorAuto, console.WithUTC(true))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN message\n", output)
+}
+
+func writeRecord(t *testing.T, record string, color console.Color, options ...console.Option) string {
+	out, err := os.Create(filepath.Join(t.TempDir(), "console.log"))
+	require.NoError(t, err)
+	defer out.Close()
+	writer := console.NewWriter(out, color, time.RFC3339, options...)
+	_, err = writer.Write([]byte(record))
+	require.NoError(t, err)
+	output, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(output)
+}
+
+func TestTheme(t *testing.T) {
+	output := writeRecord(t, testRecord, console.ColorOn, console.WithUTC(true), console.WithTheme(console.MonochromeBoldTheme))
+	require.Equal(t, "2024-01-01T12:00:00Z \x1b[1mWRN\x1b[0m \x1b[1mmessage\x1b[0m\n", output)
+}
+
+func TestColorDepth(t *testing.T) {
+	theme := &console.Theme{Levels: map[zerolog.Level]string{zerolog.WarnLevel: console.TrueColor(255, 135, 0)}}
+	output := writeRecord(t, testRecord, console.ColorOn, console.WithUTC(true), console.WithTheme(theme), console.WithColorDepth(console.ColorDepthTrue))
+	require.Equal(t, "2024-01-01T12:00:00Z \x1b[38;2;255;135;0mWRN\x1b[0m message\n", output)
+	output = writeRecord(t, testRecord, console.ColorOn, console.WithUTC(true), console.WithTheme(theme), console.WithColorDepth(console.ColorDepth256))
+	require.Equal(t, "2024-01-01T12:00:00Z \x1b[38;5;214mWRN\x1b[0m message\n", output)
+	output = writeRecord(t, testRecord, console.ColorOn, console.WithUTC(true), console.WithTheme(theme), console.WithColorDepth(console.ColorDepth16))
+	require.Equal(t, "2024-01-01T12:00:00Z \x1b[33mWRN\x1b[0m message\n", output)
+}
+
+func TestLevelNames(t *testing.T) {
+	levelNames := map[zerolog.Level]string{zerolog.WarnLevel: "WARNING", zerolog.Level(10): "AUDIT"}
+	output := writeRecord(t, testRecord, console.ColorOff, console.WithUTC(true), console.WithLevelNames(levelNames))
+	require.Equal(t, "2024-01-01T12:00:00Z WARNING message\n", output)
+	output = writeRecord(t, `{"level":"10","time":"2024-01-01T12:00:00Z","message":"message"}`, console.ColorOff, console.WithUTC(true), console.WithLevelNames(levelNames))
+	require.Equal(t, "2024-01-01T12:00:00Z AUDIT message\n", output)
+}
+
+func TestSourcePath(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	caller := filepath.Join(cwd, "console.go") + ":42"
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","caller":"` + caller + `","message":"message"}`
+	output := writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathFull))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN "+caller+" > message\n", output)
+	output = writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathRelative))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN console.go:42 > message\n", output)
+	output = writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathShort))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN console/console.go:42 > message\n", output)
+}
+
+func TestFunctionName(t *testing.T) {
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","caller":"/src/main.go:42 github.com/example/app/server.(*Server).Run","message":"message"}`
+	output := writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathFull))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN /src/main.go:42 > message\n", output)
+	output = writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathFull), console.WithFunctionName(true))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN /src/main.go:42 server.(*Server).Run > message\n", output)
+}
+
+func TestLayout(t *testing.T) {
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","caller":"/src/main.go:42","key":"value","error":"failure","message":"message"}`
+	output := writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathFull))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN /src/main.go:42 > message error=failure key=value\n", output)
+	output = writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathFull), console.WithLayout("{level:-5}|{message:10}|{unknown}"))
+	require.Equal(t, "WRN  |   message|{unknown}\n", output)
+	output = writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithSourcePath(console.SourcePathFull), console.WithLayout(""))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN /src/main.go:42 > message error=failure key=value\n", output)
+}
+
+func TestMultiline(t *testing.T) {
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","key1":"value1","key2":"value2","message":"line 1\nline 2\n"}`
+	output := writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithMultiline(true), console.WithFieldLines(true))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN line 1\n    line 2\n    key1=value1\n    key2=value2\n", output)
+}
+
+func TestPrettyJSON(t *testing.T) {
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","key":{"a":[1,2]},"message":"message"}`
+	output := writeRecord(t, record, console.ColorOff, console.WithUTC(true))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN message key={\"a\":[1,2]}\n", output)
+	output = writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithFieldLines(true), console.WithPrettyJSON(true))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN message\n    key={\n      \"a\": [\n        1,\n        2\n      ]\n    }\n", output)
+}
+
+func TestHyperlinks(t *testing.T) {
+	t.Setenv("TERM_PROGRAM", "vscode")
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","caller":"/src/main.go:42","message":"message"}`
+	output := writeRecord(t, record, console.ColorOn, console.WithUTC(true), console.WithTheme(&console.Theme{}), console.WithSourcePath(console.SourcePathFull), console.WithHyperlinks(console.VSCodeHyperlinks))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN \x1b]8;;vscode://file/src/main.go:42\x1b\\/src/main.go:42\x1b]8;;\x1b\\ > message\n", output)
+}
+
+func TestFileHyperlinks(t *testing.T) {
+	t.Setenv("TERM_PROGRAM", "vscode")
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","caller":"C:/my src/main.go:42","message":"message"}`
+	output := writeRecord(t, record, console.ColorOn, console.WithUTC(true), console.WithTheme(&console.Theme{}), console.WithSourcePath(console.SourcePathFull), console.WithHyperlinks(console.FileHyperlinks))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN \x1b]8;;file:///C:/my%20src/main.go\x1b\\C:/my src/main.go:42\x1b]8;;\x1b\\ > message\n", output)
+}
+
+func TestHyperlinksPadding(t *testing.T) {
+	t.Setenv("TERM_PROGRAM", "vscode")
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","caller":"/src/main.go:42","message":"message"}`
+	output := writeRecord(t, record, console.ColorOn, console.WithTheme(&console.Theme{}), console.WithSourcePath(console.SourcePathFull), console.WithHyperlinks(console.VSCodeHyperlinks), console.WithLayout("{caller:-20}|"))
+	require.Equal(t, "\x1b]8;;vscode://file/src/main.go:42\x1b\\/src/main.go:42\x1b]8;;\x1b\\ >   |\n", output)
+}
+
+func TestWideCharacterPadding(t *testing.T) {
+	record := `{"level":"debug","message":"日本"}`
+	output := writeRecord(t, record, console.ColorOff, console.WithLevelIcons(console.LevelIconsOnly, nil), console.WithLayout("{level:-4}|{message:-6}|"))
+	require.Equal(t, "🐛  |日本  |\n", output)
+}
+
+func TestTimestampDelta(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	out, err := os.Create(filepath.Join(t.TempDir(), "console.log"))
+	require.NoError(t, err)
+	defer out.Close()
+	writer := console.NewWriter(out, console.ColorOff, time.RFC3339, console.WithTimestampMode(console.TimestampDelta), console.WithClock(clock))
+	_, err = writer.Write([]byte(`{"level":"warn","time":"2024-01-01T12:00:00Z","message":"message 1"}`))
+	require.NoError(t, err)
+	now = now.Add(34 * time.Millisecond)
+	_, err = writer.Write([]byte(`{"level":"warn","time":"2024-01-01T12:00:00Z","message":"message 2"}`))
+	require.NoError(t, err)
+	now = now.Add(1500 * time.Millisecond)
+	_, err = writer.Write([]byte(`{"level":"warn","message":"message 3"}`))
+	require.NoError(t, err)
+	output, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	require.Equal(t, "+0.000s WRN message 1\n+0.034s WRN message 2\n+1.500s WRN message 3\n", string(output))
+}
+
+func TestTimestampElapsed(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	out, err := os.Create(filepath.Join(t.TempDir(), "console.log"))
+	require.NoError(t, err)
+	defer out.Close()
+	writer := console.NewWriter(out, console.ColorOff, time.RFC3339, console.WithTimestampMode(console.TimestampElapsed), console.WithClock(clock))
+	now = now.Add(250 * time.Millisecond)
+	_, err = writer.Write([]byte(`{"level":"warn","message":"message"}`))
+	require.NoError(t, err)
+	output, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	require.Equal(t, "+0.250s WRN message\n", string(output))
+}
+
+func TestWrap(t *testing.T) {
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","key1":"value1","key2":"value2","k3":"v3","message":"message"}`
+	output := writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithWrap(40))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN message k3=v3\n    key1=value1 key2=value2\n", output)
+	output = writeRecord(t, record, console.ColorOff, console.WithUTC(true), console.WithFieldLines(true), console.WithAlignedKeys(true))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN message\n    k3  =v3\n    key1=value1\n    key2=value2\n", output)
+}
+
+func TestHighlights(t *testing.T) {
+	record := `{"level":"warn","time":"2024-01-01T12:00:00Z","duration":1500,"key":"value","message":"message"}`
+	output := writeRecord(t, record, console.ColorOn, console.WithUTC(true), console.WithTheme(&console.Theme{}), console.WithHighlights(console.HighlightRule{Key: "duration", Color: "33", Match: console.Above(1000)}, console.HighlightRule{Key: "key", Color: "1"}))
+	require.Equal(t, "2024-01-01T12:00:00Z WRN message duration=\x1b[33m1500\x1b[0m key=\x1b[1mvalue\x1b[0m\n", output)
+}
+
+func TestLevelIcons(t *testing.T) {
+	output := writeRecord(t, testRecord, console.ColorOff, console.WithUTC(true), console.WithLevelIcons(console.LevelIconsPrefix, nil))
+	require.Equal(t, "2024-01-01T12:00:00Z ⚠ WRN message\n", output)
+	output = writeRecord(t, testRecord, console.ColorOff, console.WithUTC(true), console.WithLevelIcons(console.LevelIconsOnly, map[zerolog.Level]string{zerolog.WarnLevel: "!"}))
+	require.Equal(t, "2024-01-01T12:00:00Z ! message\n", output)
+}
+
+func TestDeterministic(t *testing.T) {
+	record := `{"level":"info","time":"2024-01-01T12:00:00Z","caller":"/src/main.go:42","message":"message"}`
+	output := writeRecord(t, record, console.ColorOn, console.WithDeterministic(true), console.WithSourcePath(console.SourcePathFull), console.WithLevelNames(map[zerolog.Level]string{zerolog.WarnLevel: "WARNING"}))
+	require.Equal(t, "INF     /src/main.go > message\n", output)
+}
+
+func BenchmarkPlainWriter(b *testing.B) {
+	writer := console.NewPlainWriter(io.Discard, time.RFC3339, console.WithUTC(true))
+	record := []byte(`{"level":"info","time":"2024-01-01T12:00:00Z","caller":"/src/main.go:42","key":"value","count":42,"quoted":"a b","error":"failure","message":"message"}`)
+	b.ReportAllocs()
+	for range b.N {
+		_, err := writer.Write(record)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,520 @@
+// format.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package console
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const unknownLevel = "???"
+
+type format struct {
+	theme      *Theme
+	noColor    bool
+	timeFormat string
+	location   *time.Location
+	levelNames map[zerolog.Level]string
+	sourcePath SourcePath
+	function   bool
+	multiline  bool
+	fieldLines bool
+	prettyJSON bool
+	hyperlinks string
+	wrapWidth  int
+	alignKeys  bool
+	highlights []HighlightRule
+	iconMode   LevelIconMode
+	levelIcons map[zerolog.Level]string
+
+	timestampMode TimestampMode
+	clock         func() time.Time
+	start         time.Time
+	deterministic bool
+	levelWidth    int
+	mutex         sync.Mutex
+	lastTimestamp time.Time
+	callerMutex   sync.RWMutex
+	callers       map[string]string
+}
+
+func (f *format) colorize(s string, code string) string {
+	if f.noColor || code == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func (f *format) appendColorStart(dst []byte, code string) []byte {
+	if f.noColor || code == "" {
+		return dst
+	}
+	dst = append(dst, "\x1b["...)
+	dst = append(dst, code...)
+	return append(dst, 'm')
+}
+
+func (f *format) appendColorEnd(dst []byte, code string) []byte {
+	if f.noColor || code == "" {
+		return dst
+	}
+	return append(dst, "\x1b[0m"...)
+}
+
+// appendPart appends the given part to dst. Parts rendered for every record are appended
+// directly to keep allocations on the hot path down.
+func (f *format) appendPart(dst []byte, part string, evt map[string]interface{}) []byte {
+	switch part {
+	case layoutTime:
+		return f.appendTimestamp(dst, evt[zerolog.TimestampFieldName])
+	case layoutFields:
+		return f.appendFields(dst, evt)
+	}
+	return append(dst, f.formatPart(part, evt)...)
+}
+
+func (f *format) formatPart(part string, evt map[string]interface{}) string {
+	switch part {
+	case layoutTime:
+		return f.formatTimestamp(evt[zerolog.TimestampFieldName])
+	case layoutLevel:
+		return f.formatLevel(evt[zerolog.LevelFieldName])
+	case layoutCaller:
+		return f.formatCaller(evt[zerolog.CallerFieldName])
+	case layoutMessage:
+		return f.formatMessage(evt[zerolog.MessageFieldName], recordLevel(evt))
+	case layoutFields:
+		return f.formatFields(evt)
+	}
+	return ""
+}
+
+func recordLevel(evt map[string]interface{}) zerolog.Level {
+	levelString, ok := evt[zerolog.LevelFieldName].(string)
+	if !ok {
+		return zerolog.NoLevel
+	}
+	level, err := zerolog.ParseLevel(levelString)
+	if err != nil {
+		return zerolog.NoLevel
+	}
+	return level
+}
+
+func (f *format) formatTimestamp(i interface{}) string {
+	if f.deterministic {
+		return ""
+	}
+	var timestamp string
+	switch f.timestampMode {
+	case TimestampElapsed:
+		timestamp = formatDuration(f.clock().Sub(f.start))
+	case TimestampDelta:
+		timestamp = formatDuration(f.delta(f.clock()))
+	default:
+		if i == nil {
+			return ""
+		}
+		parsed, err := parseTimestamp(i, f.location)
+		if err != nil {
+			timestamp = fmt.Sprintf("%v", i)
+		} else {
+			timestamp = parsed.In(f.location).Format(f.timeFormat)
+		}
+	}
+	return f.colorize(timestamp, f.theme.Timestamp)
+}
+
+func (f *format) appendTimestamp(dst []byte, i interface{}) []byte {
+	if f.deterministic || f.timestampMode != TimestampAbsolute || i == nil {
+		return append(dst, f.formatTimestamp(i)...)
+	}
+	parsed, err := parseTimestamp(i, f.location)
+	if err != nil {
+		return append(dst, f.formatTimestamp(i)...)
+	}
+	dst = f.appendColorStart(dst, f.theme.Timestamp)
+	dst = parsed.In(f.location).AppendFormat(dst, f.timeFormat)
+	return f.appendColorEnd(dst, f.theme.Timestamp)
+}
+
+var processStart = time.Now()
+
+func (f *format) delta(now time.Time) time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var delta time.Duration
+	if !f.lastTimestamp.IsZero() {
+		delta = now.Sub(f.lastTimestamp)
+	}
+	f.lastTimestamp = now
+	return delta
+}
+
+func formatDuration(duration time.Duration) string {
+	return fmt.Sprintf("%+.3fs", duration.Seconds())
+}
+
+func parseTimestamp(i interface{}, location *time.Location) (time.Time, error) {
+	switch value := i.(type) {
+	case string:
+		return time.ParseInLocation(zerolog.TimeFieldFormat, value, location)
+	case json.Number:
+		unix, err := value.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return unixTime(unix), nil
+	}
+	return time.Time{}, fmt.Errorf("unexpected timestamp type %T", i)
+}
+
+func unixTime(unix int64) time.Time {
+	switch zerolog.TimeFieldFormat {
+	case zerolog.TimeFormatUnixNano:
+		return time.Unix(0, unix)
+	case zerolog.TimeFormatUnixMicro:
+		return time.UnixMicro(unix)
+	case zerolog.TimeFormatUnixMs:
+		return time.UnixMilli(unix)
+	}
+	return time.Unix(unix, 0)
+}
+
+func (f *format) formatLevel(i interface{}) string {
+	levelString, ok := i.(string)
+	if !ok {
+		if i == nil {
+			return unknownLevel
+		}
+		return stripLevel(fmt.Sprintf("%s", i))
+	}
+	level, err := zerolog.ParseLevel(levelString)
+	if err != nil {
+		return stripLevel(levelString)
+	}
+	formattedLevel, ok := f.levelNames[level]
+	if !ok {
+		formattedLevel, ok = zerolog.FormattedLevels[level]
+	}
+	if !ok {
+		formattedLevel = stripLevel(levelString)
+	}
+	icon, ok := f.levelIcons[level]
+	switch {
+	case !ok || f.iconMode == LevelIconsOff:
+	case f.iconMode == LevelIconsOnly:
+		formattedLevel = icon
+	default:
+		formattedLevel = icon + " " + formattedLevel
+	}
+	return f.colorize(pad(formattedLevel, -f.levelWidth), f.theme.Levels[level])
+}
+
+func (f *format) maxLevelWidth() int {
+	levelWidth := 0
+	for level := zerolog.TraceLevel; level <= zerolog.PanicLevel; level++ {
+		levelWidth = max(levelWidth, visibleWidth(f.formatLevel(level.String())))
+	}
+	for level := range f.levelNames {
+		levelWidth = max(levelWidth, visibleWidth(f.formatLevel(level.String())))
+	}
+	return levelWidth
+}
+
+func stripLevel(level string) string {
+	if len(level) == 0 {
+		return unknownLevel
+	}
+	if len(level) > 3 {
+		level = level[:3]
+	}
+	return strings.ToUpper(level)
+}
+
+// formatCaller formats the given caller.
+//
+// As the number of call sites is bounded, formatted callers are cached to avoid
+// resolving the source path for every record.
+func (f *format) formatCaller(i interface{}) string {
+	caller, _ := i.(string)
+	if caller == "" {
+		return ""
+	}
+	f.callerMutex.RLock()
+	formatted, ok := f.callers[caller]
+	f.callerMutex.RUnlock()
+	if ok {
+		return formatted
+	}
+	formatted = f.resolveCaller(caller)
+	f.callerMutex.Lock()
+	if f.callers == nil {
+		f.callers = make(map[string]string)
+	}
+	f.callers[caller] = formatted
+	f.callerMutex.Unlock()
+	return formatted
+}
+
+func (f *format) resolveCaller(caller string) string {
+	caller, function := splitCaller(caller)
+	if f.deterministic {
+		lastColon := strings.LastIndexByte(caller, ':')
+		if lastColon >= 0 {
+			caller = caller[:lastColon]
+		}
+	}
+	displayCaller := caller
+	switch f.sourcePath {
+	case SourcePathRelative:
+		displayCaller = relativeSourcePath(caller)
+	case SourcePathShort:
+		displayCaller = shortSourcePath(caller)
+	}
+	if f.function && function != "" {
+		displayCaller += " " + shortFunctionName(function)
+	}
+	return f.hyperlink(f.colorize(displayCaller, f.theme.Caller), caller) + f.colorize(" >", f.theme.CallerMarker)
+}
+
+// splitCaller splits a caller of the form "path:line function" (see [github.com/tdrn-org/go-log.CallerWithFunction])
+// into its location and function part.
+func splitCaller(caller string) (string, string) {
+	lastSpace := strings.LastIndexByte(caller, ' ')
+	if lastSpace < 0 {
+		return caller, ""
+	}
+	location := caller[:lastSpace]
+	lastColon := strings.LastIndexByte(location, ':')
+	if lastColon < 0 {
+		return caller, ""
+	}
+	_, err := strconv.Atoi(location[lastColon+1:])
+	if err != nil {
+		return caller, ""
+	}
+	return location, caller[lastSpace+1:]
+}
+
+// shortFunctionName strips the package path from a fully qualified function name
+// (e.g. "github.com/tdrn-org/go-log/console.(*format).formatCaller" -> "console.(*format).formatCaller").
+func shortFunctionName(function string) string {
+	return function[strings.LastIndexByte(function, '/')+1:]
+}
+
+func (f *format) hyperlink(s string, caller string) string {
+	if f.noColor || f.hyperlinks == "" {
+		return s
+	}
+	path, line := caller, "1"
+	lastColon := strings.LastIndexByte(caller, ':')
+	if lastColon >= 0 {
+		path, line = caller[:lastColon], caller[lastColon+1:]
+	}
+	link := strings.NewReplacer("{path}", urlPath(path), "{line}", line).Replace(f.hyperlinks)
+	return "\x1b]8;;" + link + "\x1b\\" + s + "\x1b]8;;\x1b\\"
+}
+
+// urlPath converts a source path into an escaped URL path (e.g. "C:\\my src\\main.go" -> "/C:/my%20src/main.go").
+func urlPath(path string) string {
+	slashPath := filepath.ToSlash(path)
+	if len(slashPath) >= 2 && slashPath[1] == ':' {
+		slashPath = "/" + slashPath
+	}
+	return (&url.URL{Scheme: "file", Path: slashPath}).EscapedPath()
+}
+
+func relativeSourcePath(path string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	relPath, err := filepath.Rel(cwd, path)
+	if err != nil {
+		return path
+	}
+	return relPath
+}
+
+var moduleRoots sync.Map
+
+func shortSourcePath(path string) string {
+	modIndex := strings.LastIndex(path, "/pkg/mod/")
+	if modIndex >= 0 {
+		return path[modIndex+len("/pkg/mod/"):]
+	}
+	moduleRoot := findModuleRoot(filepath.Dir(path))
+	if moduleRoot == "" {
+		return relativeSourcePath(path)
+	}
+	relPath, err := filepath.Rel(moduleRoot, path)
+	if err != nil {
+		return path
+	}
+	return relPath
+}
+
+func findModuleRoot(dir string) string {
+	cached, ok := moduleRoots.Load(dir)
+	if ok {
+		return cached.(string)
+	}
+	moduleRoot := ""
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	if err == nil {
+		moduleRoot = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		moduleRoot = findModuleRoot(parent)
+	}
+	moduleRoots.Store(dir, moduleRoot)
+	return moduleRoot
+}
+
+func (f *format) formatMessage(i interface{}, level zerolog.Level) string {
+	if i == nil || i == "" {
+		return ""
+	}
+	message, ok := i.(string)
+	if !ok {
+		message = fmt.Sprintf("%s", i)
+	}
+	return f.colorize(message, f.theme.Messages[level])
+}
+
+func (f *format) formatFields(evt map[string]interface{}) string {
+	return strings.Join(f.formatFieldList(evt), " ")
+}
+
+func (f *format) formatFieldList(evt map[string]interface{}) []string {
+	fields := f.fieldNames(make([]string, 0, len(evt)), evt)
+	formatted := make([]string, 0, len(fields))
+	for _, field := range fields {
+		formatted = append(formatted, f.formatField(field, evt[field]))
+	}
+	return formatted
+}
+
+func (f *format) appendFields(dst []byte, evt map[string]interface{}) []byte {
+	var fieldsArray [16]string
+	fields := f.fieldNames(fieldsArray[:0], evt)
+	for i, field := range fields {
+		if i > 0 {
+			dst = append(dst, ' ')
+		}
+		dst = f.appendField(dst, field, evt[field])
+	}
+	return dst
+}
+
+// fieldNames appends the names of the record's fields (error first, then sorted by name) to fields.
+func (f *format) fieldNames(fields []string, evt map[string]interface{}) []string {
+	for field := range evt {
+		switch field {
+		case zerolog.LevelFieldName, zerolog.TimestampFieldName, zerolog.MessageFieldName, zerolog.CallerFieldName:
+			continue
+		}
+		fields = append(fields, field)
+	}
+	slices.SortFunc(fields, compareFields)
+	return fields
+}
+
+func compareFields(field1 string, field2 string) int {
+	switch {
+	case field1 == field2:
+		return 0
+	case field1 == zerolog.ErrorFieldName:
+		return -1
+	case field2 == zerolog.ErrorFieldName:
+		return 1
+	}
+	return strings.Compare(field1, field2)
+}
+
+func (f *format) formatField(field string, value interface{}) string {
+	return string(f.appendField(nil, field, value))
+}
+
+func (f *format) appendField(dst []byte, field string, value interface{}) []byte {
+	keyColor := f.theme.Key
+	valueColor := f.theme.Value
+	if field == zerolog.ErrorFieldName {
+		keyColor = f.theme.ErrorKey
+		valueColor = f.theme.ErrorValue
+	}
+	for _, highlight := range f.highlights {
+		if highlight.Key == field && (highlight.Match == nil || highlight.Match(value)) {
+			valueColor = highlight.Color
+			break
+		}
+	}
+	dst = f.appendColorStart(dst, keyColor)
+	dst = append(dst, field...)
+	dst = append(dst, '=')
+	dst = f.appendColorEnd(dst, keyColor)
+	dst = f.appendColorStart(dst, valueColor)
+	dst = f.appendValue(dst, value)
+	return f.appendColorEnd(dst, valueColor)
+}
+
+func (f *format) appendValue(dst []byte, value interface{}) []byte {
+	switch typedValue := value.(type) {
+	case string:
+		if needsQuote(typedValue) {
+			return strconv.AppendQuote(dst, typedValue)
+		}
+		return append(dst, typedValue...)
+	case json.Number:
+		return append(dst, typedValue...)
+	}
+	return append(dst, f.formatValue(value)...)
+}
+
+func (f *format) formatValue(value interface{}) string {
+	switch typedValue := value.(type) {
+	case string:
+		if needsQuote(typedValue) {
+			return strconv.Quote(typedValue)
+		}
+		return typedValue
+	case json.Number:
+		return typedValue.String()
+	case map[string]interface{}, []interface{}:
+		if f.prettyJSON {
+			marshaled, err := json.MarshalIndent(typedValue, multilineIndent, "  ")
+			if err == nil {
+				return string(marshaled)
+			}
+		}
+	}
+	marshaled, err := zerolog.InterfaceMarshalFunc(value)
+	if err != nil {
+		return fmt.Sprintf("[error: %v]", err)
+	}
+	return string(marshaled)
+}
+
+func needsQuote(s string) bool {
+	for i := range s {
+		if s[i] < 0x20 || s[i] > 0x7e || s[i] == ' ' || s[i] == '\\' || s[i] == '"' {
+			return true
+		}
+	}
+	return false
+}
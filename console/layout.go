@@ -0,0 +1,245 @@
+// layout.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package console
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/text/width"
+)
+
+// DefaultLayout defines the layout used if no other layout is selected.
+const DefaultLayout = "{time} {level} {caller} {message} {fields}"
+
+const (
+	layoutTime    = "time"
+	layoutLevel   = "level"
+	layoutCaller  = "caller"
+	layoutMessage = "message"
+	layoutFields  = "fields"
+)
+
+var layoutParts = map[string]string{
+	"time":    layoutTime,
+	"level":   layoutLevel,
+	"caller":  layoutCaller,
+	"source":  layoutCaller,
+	"message": layoutMessage,
+	"fields":  layoutFields,
+	"attrs":   layoutFields,
+}
+
+type layoutSegment struct {
+	literal string
+	part    string
+	width   int
+}
+
+// parseLayout parses a layout string like "{time} {level} [{caller}] {message} {fields}".
+//
+// Placeholders may carry a printf like width (e.g. "{level:-5}" or "{level:5}") to pad the
+// corresponding part to the given width. Unknown placeholders are kept as literal text.
+func parseLayout(layout string) []layoutSegment {
+	segments := make([]layoutSegment, 0)
+	literal := strings.Builder{}
+	for len(layout) > 0 {
+		start := strings.IndexByte(layout, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(layout[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		literal.WriteString(layout[:start])
+		name, widthString, _ := strings.Cut(layout[start+1:end], ":")
+		part, known := layoutParts[name]
+		width, err := strconv.Atoi(widthString)
+		if !known || (widthString != "" && err != nil) {
+			literal.WriteString(layout[start : end+1])
+		} else {
+			segments = append(segments, layoutSegment{literal: literal.String(), part: part, width: width})
+			literal.Reset()
+		}
+		layout = layout[end+1:]
+	}
+	literal.WriteString(layout)
+	if literal.Len() > 0 {
+		segments = append(segments, layoutSegment{literal: literal.String()})
+	}
+	return segments
+}
+
+const multilineIndent = "    "
+
+func (f *format) render(line []byte, segments []layoutSegment, evt map[string]interface{}) []byte {
+	var messageLines []string
+	var fieldLines []string
+	for _, segment := range segments {
+		start := len(line)
+		line = append(line, segment.literal...)
+		valueStart := len(line)
+		switch {
+		case segment.part == layoutMessage && f.multiline:
+			message, _ := evt[zerolog.MessageFieldName].(string)
+			message, continuation, _ := strings.Cut(strings.TrimRight(message, "\r\n"), "\n")
+			line = append(line, pad(f.formatMessage(message, recordLevel(evt)), segment.width)...)
+			if continuation != "" {
+				messageLines = strings.Split(continuation, "\n")
+			}
+		case segment.part == layoutFields && f.fieldLines:
+			fieldLines = f.alignFields(evt)
+		case segment.part == layoutFields && f.wrapWidth > 0:
+			line = f.appendWrapped(line[:start], segment.literal, f.formatFieldList(evt))
+			continue
+		case segment.part != "" && segment.width != 0:
+			line = append(line, pad(f.formatPart(segment.part, evt), segment.width)...)
+		case segment.part != "":
+			line = f.appendPart(line, segment.part, evt)
+		}
+		// Blank literals only separate non-empty parts
+		if strings.TrimSpace(segment.literal) == "" && (len(line) == valueStart || start == 0) {
+			line = append(line[:start], line[valueStart:]...)
+		}
+	}
+	for _, messageLine := range messageLines {
+		line = append(line, '\n')
+		line = append(line, multilineIndent...)
+		line = append(line, f.formatMessage(strings.TrimRight(messageLine, "\r"), recordLevel(evt))...)
+	}
+	for _, fieldLine := range fieldLines {
+		line = append(line, '\n')
+		line = append(line, multilineIndent...)
+		line = append(line, fieldLine...)
+	}
+	return append(line, '\n')
+}
+
+func (f *format) appendWrapped(line []byte, literal string, fields []string) []byte {
+	lineStart := bytes.LastIndexByte(line, '\n') + 1
+	for i, field := range fields {
+		separator := literal
+		if i > 0 {
+			separator = " "
+		}
+		lineWidth := visibleWidth(string(line[lineStart:]))
+		if lineWidth > len(multilineIndent) && lineWidth+len(separator)+visibleWidth(field) > f.wrapWidth {
+			line = append(line, '\n')
+			lineStart = len(line)
+			line = append(line, multilineIndent...)
+		} else if lineWidth > 0 || strings.TrimSpace(separator) != "" {
+			line = append(line, separator...)
+		}
+		line = append(line, field...)
+	}
+	return line
+}
+
+func (f *format) alignFields(evt map[string]interface{}) []string {
+	fields := f.formatFieldList(evt)
+	if !f.alignKeys {
+		return fields
+	}
+	keyWidth := 0
+	for _, field := range fields {
+		keyWidth = max(keyWidth, visibleWidth(fieldKey(field)))
+	}
+	aligned := make([]string, 0, len(fields))
+	for _, field := range fields {
+		key := fieldKey(field)
+		aligned = append(aligned, key+strings.Repeat(" ", keyWidth-visibleWidth(key))+field[len(key):])
+	}
+	return aligned
+}
+
+func fieldKey(field string) string {
+	keyEnd := strings.IndexByte(field, '=')
+	if keyEnd < 0 {
+		return ""
+	}
+	return field[:keyEnd]
+}
+
+func pad(s string, width int) string {
+	if width == 0 || s == "" {
+		return s
+	}
+	padding := max(width, -width) - visibleWidth(s)
+	if padding <= 0 {
+		return s
+	}
+	if width < 0 {
+		return s + strings.Repeat(" ", padding)
+	}
+	return strings.Repeat(" ", padding) + s
+}
+
+func visibleWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			i += escapeLength(s[i:])
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth gets the number of terminal cells occupied by the given rune.
+//
+// East Asian wide and fullwidth characters (including most emojis) occupy two cells,
+// combining marks and other zero width characters (e.g. variation selectors) none.
+func runeWidth(r rune) int {
+	if r == '\u200d' || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Variation_Selector) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// escapeLength gets the length of the escape sequence at the beginning of the given string.
+//
+// Besides SGR (ESC [ ... m) and other CSI sequences, OSC sequences (ESC ] ... ST) like the ones
+// used for hyperlinks are recognized. Unterminated sequences extend to the end of the string.
+func escapeLength(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	default:
+		return 2
+	}
+	return len(s)
+}
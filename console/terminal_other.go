@@ -0,0 +1,19 @@
+// terminal_other.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+//go:build !windows
+
+package console
+
+import (
+	"os"
+)
+
+// enableVirtualTerminal is a no-op on platforms natively supporting ANSI escape sequences.
+func enableVirtualTerminal(_ *os.File) bool {
+	return true
+}
@@ -0,0 +1,31 @@
+// terminal_windows.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+//go:build windows
+
+package console
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableVirtualTerminal enables ANSI escape sequence processing for the given console output.
+// If enabling fails (e.g. on older Windows versions), escape sequences are emulated.
+func enableVirtualTerminal(out *os.File) bool {
+	handle := windows.Handle(out.Fd())
+	var mode uint32
+	err := windows.GetConsoleMode(handle, &mode)
+	if err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
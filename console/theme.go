@@ -0,0 +1,176 @@
+// theme.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package console
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+)
+
+// Theme defines the colors used for console logging.
+//
+// Each color is defined by its ANSI SGR parameters (e.g. "31" for red or "1;33" for bold yellow).
+// An empty string disables coloring for the corresponding element. 256-color and 24-bit colors
+// (see [Color256] and [TrueColor]) are downgraded automatically if the terminal does not support them.
+type Theme struct {
+	// Timestamp defines the timestamp color.
+	Timestamp string
+	// Levels defines the level colors.
+	Levels map[zerolog.Level]string
+	// Caller defines the caller color.
+	Caller string
+	// CallerMarker defines the color of the marker following the caller.
+	CallerMarker string
+	// Messages defines the message colors per level.
+	Messages map[zerolog.Level]string
+	// Key defines the field key color.
+	Key string
+	// Value defines the field value color.
+	Value string
+	// ErrorKey defines the error field key color.
+	ErrorKey string
+	// ErrorValue defines the error field value color.
+	ErrorValue string
+}
+
+// DefaultTheme is the theme used if no other theme is selected.
+var DefaultTheme = &Theme{
+	Timestamp: "90",
+	Levels: map[zerolog.Level]string{
+		zerolog.TraceLevel: "34",
+		zerolog.InfoLevel:  "32",
+		zerolog.WarnLevel:  "33",
+		zerolog.ErrorLevel: "31",
+		zerolog.FatalLevel: "31",
+		zerolog.PanicLevel: "31",
+	},
+	Caller:       "1",
+	CallerMarker: "36",
+	Messages: map[zerolog.Level]string{
+		zerolog.InfoLevel:  "1",
+		zerolog.WarnLevel:  "1",
+		zerolog.ErrorLevel: "1",
+		zerolog.FatalLevel: "1",
+		zerolog.PanicLevel: "1",
+	},
+	Key:        "36",
+	ErrorKey:   "36",
+	ErrorValue: "1;31",
+}
+
+// LightTheme is suitable for terminals with light background.
+var LightTheme = &Theme{
+	Timestamp: "2",
+	Levels: map[zerolog.Level]string{
+		zerolog.TraceLevel: "34",
+		zerolog.InfoLevel:  "32",
+		zerolog.WarnLevel:  "35",
+		zerolog.ErrorLevel: "31",
+		zerolog.FatalLevel: "31",
+		zerolog.PanicLevel: "31",
+	},
+	Caller:       "1",
+	CallerMarker: "34",
+	Messages: map[zerolog.Level]string{
+		zerolog.InfoLevel:  "1",
+		zerolog.WarnLevel:  "1",
+		zerolog.ErrorLevel: "1",
+		zerolog.FatalLevel: "1",
+		zerolog.PanicLevel: "1",
+	},
+	Key:        "34",
+	ErrorKey:   "34",
+	ErrorValue: "1;31",
+}
+
+// SoftTheme uses a softer 256-color palette (falling back to basic colors on terminals not supporting 256 colors).
+var SoftTheme = &Theme{
+	Timestamp: Color256(245),
+	Levels: map[zerolog.Level]string{
+		zerolog.TraceLevel: Color256(110),
+		zerolog.DebugLevel: Color256(146),
+		zerolog.InfoLevel:  Color256(114),
+		zerolog.WarnLevel:  Color256(221),
+		zerolog.ErrorLevel: Color256(203),
+		zerolog.FatalLevel: "1;" + Color256(203),
+		zerolog.PanicLevel: "1;" + Color256(203),
+	},
+	Caller:       Color256(250),
+	CallerMarker: Color256(109),
+	Messages: map[zerolog.Level]string{
+		zerolog.InfoLevel:  "1",
+		zerolog.WarnLevel:  "1",
+		zerolog.ErrorLevel: "1",
+		zerolog.FatalLevel: "1",
+		zerolog.PanicLevel: "1",
+	},
+	Key:        Color256(109),
+	ErrorKey:   Color256(109),
+	ErrorValue: "1;" + Color256(203),
+}
+
+// MonochromeBoldTheme uses no colors, but emphasizes warnings and errors in bold.
+var MonochromeBoldTheme = &Theme{
+	Levels: map[zerolog.Level]string{
+		zerolog.WarnLevel:  "1",
+		zerolog.ErrorLevel: "1",
+		zerolog.FatalLevel: "1",
+		zerolog.PanicLevel: "1",
+	},
+	Messages: map[zerolog.Level]string{
+		zerolog.WarnLevel:  "1",
+		zerolog.ErrorLevel: "1",
+		zerolog.FatalLevel: "1",
+		zerolog.PanicLevel: "1",
+	},
+	ErrorValue: "1",
+}
+
+func (theme *Theme) downgrade(depth ColorDepth) *Theme {
+	downgradeLevels := func(levels map[zerolog.Level]string) map[zerolog.Level]string {
+		downgraded := make(map[zerolog.Level]string, len(levels))
+		for level, code := range levels {
+			downgraded[level] = downgradeColor(code, depth)
+		}
+		return downgraded
+	}
+	return &Theme{
+		Timestamp:    downgradeColor(theme.Timestamp, depth),
+		Levels:       downgradeLevels(theme.Levels),
+		Caller:       downgradeColor(theme.Caller, depth),
+		CallerMarker: downgradeColor(theme.CallerMarker, depth),
+		Messages:     downgradeLevels(theme.Messages),
+		Key:          downgradeColor(theme.Key, depth),
+		Value:        downgradeColor(theme.Value, depth),
+		ErrorKey:     downgradeColor(theme.ErrorKey, depth),
+		ErrorValue:   downgradeColor(theme.ErrorValue, depth),
+	}
+}
+
+// HighlightRule defines a rule for highlighting the value of a specific field.
+type HighlightRule struct {
+	// Key defines the key of the field to highlight.
+	Key string
+	// Color defines the highlight color (SGR parameters).
+	Color string
+	// Match optionally restricts highlighting to matching values (nil matches all values).
+	Match func(value interface{}) bool
+}
+
+// Above creates a match function for [HighlightRule] matching all numeric values above the given threshold.
+func Above(threshold float64) func(value interface{}) bool {
+	return func(value interface{}) bool {
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		float, err := number.Float64()
+		return err == nil && float > threshold
+	}
+}
@@ -0,0 +1,52 @@
+// writer.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package console
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxPooledLine limits the size of line buffers kept for reuse.
+const maxPooledLine = 64 * 1024
+
+var linePool = sync.Pool{
+	New: func() any {
+		line := make([]byte, 0, 256)
+		return &line
+	},
+}
+
+type writer struct {
+	out    io.Writer
+	format *format
+	layout []layoutSegment
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	var evt map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	err := decoder.Decode(&evt)
+	if err != nil {
+		return 0, fmt.Errorf("cannot decode event: %w", err)
+	}
+	line := linePool.Get().(*[]byte)
+	*line = w.format.render((*line)[:0], w.layout, evt)
+	_, err = w.out.Write(*line)
+	if cap(*line) <= maxPooledLine {
+		linePool.Put(line)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
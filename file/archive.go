@@ -0,0 +1,120 @@
+// archive.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// archive moves rotated log files into a separate directory and applies its own retention to them.
+type archive struct {
+	dir        string
+	dirMode    os.FileMode
+	maxAge     int
+	maxBackups int
+}
+
+// store moves the given rotated log file into the archive directory and gets its new name.
+func (a *archive) store(rotatedPath string, filename string, now time.Time) (string, error) {
+	dirMode := a.dirMode
+	if dirMode == 0 {
+		dirMode = defaultDirMode
+	}
+	err := os.MkdirAll(a.dir, dirMode)
+	if err != nil {
+		return rotatedPath, fmt.Errorf("failed to create archive directory '%s' (cause: %w)", a.dir, err)
+	}
+	archivedPath := filepath.Join(a.dir, filepath.Base(rotatedPath))
+	err = moveFile(rotatedPath, archivedPath)
+	if err != nil {
+		return rotatedPath, fmt.Errorf("failed to archive log file '%s' (cause: %w)", rotatedPath, err)
+	}
+	return archivedPath, a.prune(filename, now)
+}
+
+// prune removes the archived log files exceeding the archive's retention.
+func (a *archive) prune(filename string, now time.Time) error {
+	return pruneBackups(a.dir, filename, a.maxAge, a.maxBackups, now)
+}
+
+// pruneBackups removes the backups of the given log file within the given directory, which exceed the given
+// retention (see [WithMaxAge] and [WithMaxBackups]).
+func pruneBackups(dir string, filename string, maxAge int, maxBackups int, now time.Time) error {
+	if maxAge <= 0 && maxBackups <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory '%s' (cause: %w)", dir, err)
+	}
+	prefix, ext := backupPattern(filename)
+	type backup struct {
+		name      string
+		timestamp time.Time
+	}
+	backups := make([]backup, 0, len(entries))
+	for _, entry := range entries {
+		timestamp, ok := backupTime(entry.Name(), prefix, ext)
+		if ok && entry.Type().IsRegular() {
+			backups = append(backups, backup{name: entry.Name(), timestamp: timestamp})
+		}
+	}
+	slices.SortFunc(backups, func(backup1 backup, backup2 backup) int {
+		return backup2.timestamp.Compare(backup1.timestamp)
+	})
+	cutoff := now.Add(-time.Duration(maxAge) * 24 * time.Hour)
+	var errs []error
+	for i, backup := range backups {
+		if (maxBackups > 0 && i >= maxBackups) || (maxAge > 0 && backup.timestamp.Before(cutoff)) {
+			err = os.Remove(filepath.Join(dir, backup.name))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// moveFile moves the given file, copying it if it cannot be renamed (e.g. because it is moved to another volume).
+func moveFile(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	err = copyFile(src, dst)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src string, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dstFile, srcFile)
+	return errors.Join(err, dstFile.Close())
+}
@@ -0,0 +1,59 @@
+// buffer.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file
+
+import (
+	"io"
+)
+
+// writeBuffer buffers the writes to the log file.
+//
+// Unlike [bufio.Writer] it has no sticky error state: data that could not be flushed
+// stays buffered and is flushed again on the next attempt.
+type writeBuffer struct {
+	out  io.Writer
+	data []byte
+	size int
+}
+
+func newWriteBuffer(out io.Writer, size int) *writeBuffer {
+	return &writeBuffer{out: out, data: make([]byte, 0, size), size: size}
+}
+
+func (b *writeBuffer) Write(p []byte) (int, error) {
+	if len(b.data)+len(p) > b.size {
+		err := b.Flush()
+		if err != nil {
+			return 0, err
+		}
+		if len(p) >= b.size {
+			return b.out.Write(p)
+		}
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (b *writeBuffer) Flush() error {
+	for len(b.data) > 0 {
+		n, err := b.out.Write(b.data)
+		b.data = b.data[:copy(b.data, b.data[n:])]
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+	}
+	return nil
+}
+
+// Reset discards the buffered data.
+func (b *writeBuffer) Reset() {
+	b.data = b.data[:0]
+}
@@ -0,0 +1,86 @@
+// encrypt.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// EncryptedSuffix defines the suffix appended to the names of encrypted rotated log files (see [WithEncryption]).
+const EncryptedSuffix = ".age"
+
+// encryption encrypts rotated log files for a set of age recipients.
+type encryption struct {
+	recipients []age.Recipient
+}
+
+// encrypt replaces the given rotated log file by its encrypted version and gets the latter's name.
+func (e *encryption) encrypt(rotatedPath string) (string, error) {
+	encryptedPath := rotatedPath + EncryptedSuffix
+	err := encryptFile(rotatedPath, encryptedPath, e.recipients)
+	if err != nil {
+		os.Remove(encryptedPath)
+		return rotatedPath, fmt.Errorf("failed to encrypt log file '%s' (cause: %w)", rotatedPath, err)
+	}
+	err = os.Remove(rotatedPath)
+	if err != nil {
+		return encryptedPath, fmt.Errorf("failed to remove encrypted log file '%s' (cause: %w)", rotatedPath, err)
+	}
+	return encryptedPath, nil
+}
+
+func encryptFile(src string, dst string, recipients []age.Recipient) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	encrypter, err := age.Encrypt(dstFile, recipients...)
+	if err == nil {
+		_, err = io.Copy(encrypter, srcFile)
+		err = errors.Join(err, encrypter.Close())
+	}
+	return errors.Join(err, dstFile.Close())
+}
+
+// ParseRecipients parses the given age recipients (e.g. "age1...") for encrypting rotated log files
+// (see [WithEncryption]).
+func ParseRecipients(recipients ...string) ([]age.Recipient, error) {
+	parsed := make([]age.Recipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		x25519Recipient, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient '%s' (cause: %w)", recipient, err)
+		}
+		parsed = append(parsed, x25519Recipient)
+	}
+	return parsed, nil
+}
+
+// invalidRecipient fails any encryption. It is used in place of recipients that cannot be parsed, to
+// make sure the affected log files are not left unencrypted silently.
+type invalidRecipient struct {
+	err error
+}
+
+func (r *invalidRecipient) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	return nil, r.err
+}
@@ -9,30 +9,463 @@
 package file
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
 
+	"filippo.io/age"
+	"github.com/rs/zerolog"
+	"github.com/tdrn-org/go-log/console"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+const defaultMaxSize = 100
+const defaultFlushInterval = 1 * time.Second
+const kilobyte = 1024
+
+// Option customizes the file writer created by [NewWriter].
+type Option func(*writerOptions)
+
+type writerOptions struct {
+	maxSize           int64
+	maxAge            int
+	maxBackups        int
+	compress          bool
+	maxTotalSize      int64
+	interval          RotateInterval
+	rotateOffset      time.Duration
+	bufferSize        int
+	flushInterval     time.Duration
+	syncMode          SyncMode
+	fileMode          fs.FileMode
+	dirMode           fs.FileMode
+	uid               int
+	gid               int
+	onRotate          func(oldPath string, newPath string)
+	archiveDir        string
+	archiveMaxAge     int
+	archiveMaxBackups int
+	recipients        []age.Recipient
+	onError           func(err error)
+	fallback          io.Writer
+	fallbackOwned     bool
+	fallbackSize      int
+	diskFullLevel     zerolog.Level
+	header            func(previousPath string) []byte
+	gzip              bool
+	maxRecords        int64
+	clock             func() time.Time
+	render            func(p []byte) []byte
+}
+
+// WithMaxSize sets the size in bytes at which the log file is rotated (defaults to 100 MB).
+func WithMaxSize(size int64) Option {
+	return func(options *writerOptions) {
+		options.maxSize = size
+	}
+}
+
+// WithMaxAge sets the number of days to keep rotated log files (0 keeps them regardless of their age).
+func WithMaxAge(days int) Option {
+	return func(options *writerOptions) {
+		options.maxAge = days
+	}
+}
+
+// WithMaxBackups sets the maximum number of rotated log files to keep (0 keeps all of them).
+func WithMaxBackups(backups int) Option {
+	return func(options *writerOptions) {
+		options.maxBackups = backups
+	}
+}
+
+// WithCompress sets whether rotated log files are gzip compressed.
+func WithCompress(compress bool) Option {
+	return func(options *writerOptions) {
+		options.compress = compress
+	}
+}
+
+// WithMaxRecords sets the number of log records after which the log file is rotated (0 for unlimited).
+//
+// Every write is counted as one record; the header (see [WithHeader]) is not counted.
+func WithMaxRecords(records int64) Option {
+	return func(options *writerOptions) {
+		options.maxRecords = records
+	}
+}
+
+// WithMaxTotalSize sets the maximum total size in bytes of the log file and its rotated log files (0 for unlimited).
+//
+// After every rotation the oldest rotated log files are removed until the limit is met.
+func WithMaxTotalSize(size int64) Option {
+	return func(options *writerOptions) {
+		options.maxTotalSize = size
+	}
+}
+
+// WithRotateInterval sets the interval for time based rotation (defaults to [RotateNever]).
+func WithRotateInterval(interval RotateInterval) Option {
+	return func(options *writerOptions) {
+		options.interval = interval
+	}
+}
+
+// WithRotateOffset shifts the boundaries of time based rotation (see [WithRotateInterval]) by the given offset.
+//
+// The offset is applied to the wall-clock time and reduced modulo the rotation interval. For example,
+// rotating daily with an offset of 3 hours rotates the log file at 03:00 local time.
+func WithRotateOffset(offset time.Duration) Option {
+	return func(options *writerOptions) {
+		options.rotateOffset = offset
+	}
+}
+
+// WithBuffer sets the size in bytes of the write buffer (0 disables buffering) as well as the
+// interval for flushing it.
+//
+// Data that cannot be flushed stays buffered and is flushed again later. Flush failures are reported
+// like write failures (see [WithOnError] and [WithDiskFullLevel]). Records that do not fit into the
+// buffer while flushing fails are written to the fallback writer (see [WithFallback]).
+func WithBuffer(size int, flushInterval time.Duration) Option {
+	return func(options *writerOptions) {
+		options.bufferSize = size
+		options.flushInterval = flushInterval
+	}
+}
+
+// WithSync sets when the log file is synced to disk (defaults to [SyncNever]).
+func WithSync(syncMode SyncMode) Option {
+	return func(options *writerOptions) {
+		options.syncMode = syncMode
+	}
+}
+
+// WithFileMode sets the permissions used for creating the log file and its directory.
+//
+// A mode of 0 selects the default permissions (0600 for the log file and 0755 for the directory).
+func WithFileMode(fileMode fs.FileMode, dirMode fs.FileMode) Option {
+	return func(options *writerOptions) {
+		options.fileMode = fileMode
+		options.dirMode = dirMode
+	}
+}
+
+// WithOwner sets the owner and group used for creating the log file (-1 keeps the default).
+func WithOwner(uid int, gid int) Option {
+	return func(options *writerOptions) {
+		options.uid = uid
+		options.gid = gid
+	}
+}
+
+// WithOnRotate sets the function invoked asynchronously after the log file has been rotated.
+//
+// The function receives the name of the rotated file and the name of the new log file. If compression is
+// enabled (see [WithCompress]), the function is invoked once the rotated file has been replaced by its
+// compressed version (suffix ".gz") and receives the compressed file's name. The rotated file may already
+// have been removed due to the configured retention (see [WithMaxAge] and [WithMaxBackups]), hence the
+// function must be prepared for it to be gone.
+func WithOnRotate(onRotate func(oldPath string, newPath string)) Option {
+	return func(options *writerOptions) {
+		options.onRotate = onRotate
+	}
+}
+
+// WithArchive sets the directory rotated log files are moved to (an empty directory disables archiving).
+//
+// The archive directory may be located on another volume. Rotated log files are moved after they have
+// been compressed (see [WithCompress]) and encrypted (see [WithEncryption]) and before the rotation hook is invoked (see [WithOnRotate]), which
+// receives the archived file's name. Archived files are no longer subject to [WithMaxAge], [WithMaxBackups]
+// and [WithMaxTotalSize], but to their own retention (see [WithArchiveRetention]). Archiving failures are
+// reported like write failures (see [WithOnError]).
+func WithArchive(dir string) Option {
+	return func(options *writerOptions) {
+		options.archiveDir = dir
+	}
+}
+
+// WithArchiveRetention sets the number of days to keep archived log files as well as the maximum number of archived
+// log files to keep (see [WithArchive]). A value of 0 keeps them regardless of their age or number respectively.
+func WithArchiveRetention(maxAge int, maxBackups int) Option {
+	return func(options *writerOptions) {
+		options.archiveMaxAge = maxAge
+		options.archiveMaxBackups = maxBackups
+	}
+}
+
+// WithEncryption sets the age recipients (see [ParseRecipients]) rotated log files are encrypted for (no recipients
+// disable encryption).
+//
+// Rotated log files are encrypted after they have been compressed (see [WithCompress]) and before they are
+// archived (see [WithArchive]). Encrypted files are named after the rotated file with the suffix [EncryptedSuffix]
+// appended and are still subject to the configured retention. The unencrypted file is removed. If encryption
+// fails, the unencrypted file is kept and the failure is reported like a write failure (see [WithOnError]).
+func WithEncryption(recipients ...age.Recipient) Option {
+	return func(options *writerOptions) {
+		options.recipients = recipients
+	}
+}
+
+// WithOnError sets the function invoked synchronously whenever writing to (or flushing) the log file fails.
+//
+// The function must not log to the failing file writer. Archiving failures (see [WithArchive]) are reported
+// from a background goroutine.
+func WithOnError(onError func(err error)) Option {
+	return func(options *writerOptions) {
+		options.onError = onError
+	}
+}
+
+// WithFallback sets the writer receiving the log records that cannot be written to the log file.
+//
+// Without a fallback writer, the write error is returned to the caller. The fallback writer is not
+// closed by the file writer.
+func WithFallback(fallback io.Writer) Option {
+	return func(options *writerOptions) {
+		options.fallback = fallback
+		options.fallbackOwned = false
+	}
+}
+
+// withOwnedFallback sets a fallback writer (see [WithFallback]) that is closed together with the file writer.
+func withOwnedFallback(fallback io.WriteCloser) Option {
+	return func(options *writerOptions) {
+		options.fallback = fallback
+		options.fallbackOwned = true
+	}
+}
+
+// WithFallbackBuffer sets the maximum total size in bytes of the log records buffered in memory while the
+// log file cannot be written (0 disables buffering).
+//
+// The buffered records are written to the log file as soon as it can be written again. If the buffer is
+// full, the oldest buffered records are passed to the fallback writer (see [WithFallback]) or dropped.
+func WithFallbackBuffer(size int) Option {
+	return func(options *writerOptions) {
+		options.fallbackSize = size
+	}
+}
+
+// WithDiskFullLevel sets the level below which log records are dropped while the log file's disk is full
+// (defaults to [github.com/rs/zerolog.Disabled], which disables dropping).
+//
+// As soon as a write succeeds again, a warn record reporting the number of dropped records is written.
+func WithDiskFullLevel(level zerolog.Level) Option {
+	return func(options *writerOptions) {
+		options.diskFullLevel = level
+	}
+}
+
+// WithHeader sets the function providing the header written at the beginning of every new log file.
+//
+// The function receives the name of the previous (rotated) log file, or an empty string if the log file
+// has not been created by a rotation. See [DefaultHeader] for a default header.
+func WithHeader(header func(previousPath string) []byte) Option {
+	return func(options *writerOptions) {
+		options.header = header
+	}
+}
+
+// WithGzip sets whether the log file is written as a gzip stream.
+//
+// The gzip stream is flushed whenever the write buffer is flushed (see [WithBuffer]), so a live log file can
+// be read via zcat. Every time the log file is (re)opened, a new gzip stream is appended. Size limits refer
+// to the uncompressed size. Use a file name ending with ".gz" and do not combine this option with
+// [WithCompress].
+func WithGzip(gzip bool) Option {
+	return func(options *writerOptions) {
+		options.gzip = gzip
+	}
+}
+
+// WithClock sets the function providing the current time for time based rotation.
+//
+// Defaults to the clock used for log record timestamps (see [github.com/tdrn-org/go-log.SetClock]).
+// The timestamps within the names of rotated log files are still taken from the system clock.
+func WithClock(clock func() time.Time) Option {
+	return func(options *writerOptions) {
+		options.clock = clock
+	}
+}
+
+// NewWriter creates a new [RotatingFileWriter] for logging to the given file.
+func NewWriter(filename string, options ...Option) *RotatingFileWriter {
+	writerOptions := &writerOptions{
+		maxSize:       defaultMaxSize * megabyte,
+		flushInterval: defaultFlushInterval,
+		uid:           -1,
+		gid:           -1,
+		diskFullLevel: zerolog.Disabled,
+	}
+	for _, option := range options {
+		option(writerOptions)
+	}
+	logger := &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    unlimitedLumberjackSize,
+		MaxAge:     writerOptions.maxAge,
+		MaxBackups: writerOptions.maxBackups,
+		Compress:   writerOptions.compress,
+	}
+	writer := &RotatingFileWriter{
+		logger:             logger,
+		clock:              writerOptions.clock,
+		maxSize:            writerOptions.maxSize,
+		maxTotalSize:       writerOptions.maxTotalSize,
+		maxRecords:         writerOptions.maxRecords,
+		interval:           writerOptions.interval,
+		rotateOffset:       writerOptions.rotateOffset,
+		flushEvery:         writerOptions.flushInterval,
+		syncMode:           writerOptions.syncMode,
+		fileMode:           writerOptions.fileMode,
+		dirMode:            writerOptions.dirMode,
+		uid:                writerOptions.uid,
+		gid:                writerOptions.gid,
+		onRotate:           writerOptions.onRotate,
+		onError:            writerOptions.onError,
+		fallback:           writerOptions.fallback,
+		fallbackBufferSize: writerOptions.fallbackSize,
+		diskFullLevel:      writerOptions.diskFullLevel,
+		header:             writerOptions.header,
+		render:             writerOptions.render,
+	}
+	if writerOptions.bufferSize > 0 {
+		writer.buffer = newWriteBuffer(logger, writerOptions.bufferSize)
+	}
+	if writerOptions.gzip {
+		writer.gzip = gzip.NewWriter(writer.streamTarget())
+	}
+	if writer.clock == nil {
+		writer.clock = timestampClock
+	}
+	if len(writerOptions.recipients) > 0 {
+		writer.encryption = &encryption{recipients: writerOptions.recipients}
+	}
+	if writerOptions.archiveDir != "" {
+		writer.archive = &archive{
+			dir:        writerOptions.archiveDir,
+			dirMode:    writerOptions.dirMode,
+			maxAge:     writerOptions.archiveMaxAge,
+			maxBackups: writerOptions.archiveMaxBackups,
+		}
+	}
+	if writerOptions.fallbackOwned {
+		writer.fallbackCloser = writerOptions.fallback.(io.Closer)
+	}
+	if writer.flushEvery <= 0 {
+		writer.flushEvery = defaultFlushInterval
+	}
+	return writer
+}
+
+func timestampClock() time.Time {
+	return zerolog.TimestampFunc()
+}
+
 type YAMLFileConfig struct {
-	EnabledOption    bool   `yaml:"enabled"`
-	FilenameOption   string `yaml:"filename"`
-	MaxSizeOption    int    `yaml:"max_size"`
-	MaxAgeOption     int    `yaml:"max_age"`
-	MaxBackupsOption int    `yaml:"max_backups"`
-	CompressOption   bool   `yaml:"compress"`
+	EnabledOption           bool         `yaml:"enabled"`
+	FilenameOption          string       `yaml:"filename"`
+	MaxSizeOption           MegabyteSize `yaml:"max_size"`
+	MaxAgeOption            int          `yaml:"max_age"`
+	MaxBackupsOption        int          `yaml:"max_backups"`
+	CompressOption          bool         `yaml:"compress"`
+	ArchiveDirOption        string       `yaml:"archive_dir"`
+	ArchiveMaxAgeOption     int          `yaml:"archive_max_age"`
+	ArchiveMaxBackupsOption int          `yaml:"archive_max_backups"`
+	EncryptRecipientsOption []string     `yaml:"encrypt_recipients"`
+	RotateOption            string       `yaml:"rotate"`
+	RotateOffsetOption      string       `yaml:"rotate_offset"`
+	MaxTotalSizeOption      string       `yaml:"max_total_size"`
+	MaxRecordsOption        int64        `yaml:"max_records"`
+	BufferSizeOption        string       `yaml:"buffer_size"`
+	FlushIntervalOption     string       `yaml:"flush_interval"`
+	SyncOption              string       `yaml:"sync"`
+	FileModeOption          string       `yaml:"file_mode"`
+	DirModeOption           string       `yaml:"dir_mode"`
+	OwnerOption             string       `yaml:"owner"`
+	GroupOption             string       `yaml:"group"`
+	FallbackOption          string       `yaml:"fallback"`
+	FallbackBufferOption    string       `yaml:"fallback_buffer"`
+	DiskFullLevelOption     string       `yaml:"disk_full_level"`
+	FormatOption            string       `yaml:"format"`
+	HeaderOption            bool         `yaml:"header"`
+	GzipOption              bool         `yaml:"gzip"`
+	// OnRotate is passed to the file writer (see [WithOnRotate]).
+	OnRotate func(oldPath string, newPath string) `yaml:"-"`
+	// OnError is passed to the file writer (see [WithOnError]).
+	OnError func(err error) `yaml:"-"`
+	// Clock is passed to the file writer (see [WithClock]).
+	Clock func() time.Time `yaml:"-"`
 }
 
 func (config *YAMLFileConfig) NewWriter() io.Writer {
 	if !config.EnabledOption {
 		return nil
 	}
-	return &lumberjack.Logger{
-		Filename:   config.filenameOption(),
-		MaxSize:    config.maxSizeOption(),
-		MaxAge:     config.maxAgeOption(),
-		MaxBackups: config.maxBackupsOption(),
-		Compress:   config.compressOption(),
+	options := config.options()
+	if config.HeaderOption {
+		options = append(options, WithHeader(DefaultHeader))
+	}
+	if strings.ToLower(config.FormatOption) == "plain" {
+		options = append(options, withRender(plainRenderer()))
+	}
+	return NewWriter(config.filenameOption(), options...)
+}
+
+// withRender sets the function rendering the log records (including the header) before they
+// are written to the log file. The function is invoked with the file writer's lock held.
+func withRender(render func(p []byte) []byte) Option {
+	return func(options *writerOptions) {
+		options.render = render
+	}
+}
+
+// plainRenderer renders the log records in plain text (see [console.NewPlainWriter]). Records
+// that cannot be rendered are passed through unchanged.
+func plainRenderer() func(p []byte) []byte {
+	buffer := &bytes.Buffer{}
+	plain := console.NewPlainWriter(buffer, time.RFC3339)
+	return func(p []byte) []byte {
+		buffer.Reset()
+		_, err := plain.Write(p)
+		if err != nil {
+			return p
+		}
+		return buffer.Bytes()
+	}
+}
+
+func (config *YAMLFileConfig) options() []Option {
+	return []Option{
+		WithMaxSize(config.maxSizeOption()),
+		WithMaxAge(config.maxAgeOption()),
+		WithMaxBackups(config.maxBackupsOption()),
+		WithCompress(config.compressOption()),
+		WithEncryption(config.encryptRecipientsOption()...),
+		WithArchive(config.ArchiveDirOption),
+		WithArchiveRetention(max(config.ArchiveMaxAgeOption, 0), max(config.ArchiveMaxBackupsOption, 0)),
+		WithMaxTotalSize(config.maxTotalSizeOption()),
+		WithMaxRecords(max(config.MaxRecordsOption, 0)),
+		WithRotateInterval(config.rotateOption()),
+		WithRotateOffset(config.rotateOffsetOption()),
+		WithBuffer(config.bufferSizeOption(), config.flushIntervalOption()),
+		WithSync(config.syncOption()),
+		WithFileMode(config.fileModeOption(), config.dirModeOption()),
+		WithOwner(config.ownerOption(), config.groupOption()),
+		WithOnRotate(config.OnRotate),
+		WithOnError(config.OnError),
+		config.fallbackOption(),
+		WithFallbackBuffer(config.fallbackBufferOption()),
+		WithDiskFullLevel(config.diskFullLevelOption()),
+		WithGzip(config.GzipOption),
+		WithClock(config.Clock),
 	}
 }
 
@@ -40,11 +473,28 @@ func (config *YAMLFileConfig) filenameOption() string {
 	return config.FilenameOption
 }
 
-func (config *YAMLFileConfig) maxSizeOption() int {
-	if config.MaxSizeOption < 0 {
+func (config *YAMLFileConfig) maxSizeOption() int64 {
+	if config.MaxSizeOption <= 0 {
+		return defaultMaxSize * megabyte
+	}
+	return int64(config.MaxSizeOption)
+}
+
+func (config *YAMLFileConfig) encryptRecipientsOption() []age.Recipient {
+	recipients, err := ParseRecipients(config.EncryptRecipientsOption...)
+	if err != nil {
+		// Fail the encryption instead of leaving the rotated log files unencrypted
+		return []age.Recipient{&invalidRecipient{err: err}}
+	}
+	return recipients
+}
+
+func (config *YAMLFileConfig) maxTotalSizeOption() int64 {
+	maxTotalSize, err := ParseSize(config.MaxTotalSizeOption, megabyte)
+	if err != nil || maxTotalSize < 0 {
 		return 0
 	}
-	return config.MaxSizeOption
+	return maxTotalSize
 }
 
 func (config *YAMLFileConfig) maxAgeOption() int {
@@ -64,3 +514,140 @@ func (config *YAMLFileConfig) maxBackupsOption() int {
 func (config *YAMLFileConfig) compressOption() bool {
 	return config.CompressOption
 }
+
+func (config *YAMLFileConfig) rotateOption() RotateInterval {
+	switch strings.ToLower(config.RotateOption) {
+	case "hourly":
+		return RotateHourly
+	case "daily":
+		return RotateDaily
+	case "weekly":
+		return RotateWeekly
+	}
+	return RotateNever
+}
+
+func (config *YAMLFileConfig) rotateOffsetOption() time.Duration {
+	rotateOffset, err := time.ParseDuration(config.RotateOffsetOption)
+	if err != nil {
+		return 0
+	}
+	return rotateOffset
+}
+
+func (config *YAMLFileConfig) bufferSizeOption() int {
+	bufferSize, err := ParseSize(config.BufferSizeOption, kilobyte)
+	if err != nil || bufferSize < 0 {
+		return 0
+	}
+	return int(bufferSize)
+}
+
+func (config *YAMLFileConfig) flushIntervalOption() time.Duration {
+	flushInterval, err := time.ParseDuration(config.FlushIntervalOption)
+	if err != nil || flushInterval <= 0 {
+		return defaultFlushInterval
+	}
+	return flushInterval
+}
+
+func (config *YAMLFileConfig) syncOption() SyncMode {
+	switch strings.ToLower(config.SyncOption) {
+	case "interval":
+		return SyncInterval
+	case "always":
+		return SyncAlways
+	}
+	return SyncNever
+}
+
+func (config *YAMLFileConfig) fileModeOption() fs.FileMode {
+	return parseMode(config.FileModeOption)
+}
+
+func (config *YAMLFileConfig) dirModeOption() fs.FileMode {
+	return parseMode(config.DirModeOption)
+}
+
+func parseMode(mode string) fs.FileMode {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return fs.FileMode(parsed) & fs.ModePerm
+}
+
+func (config *YAMLFileConfig) ownerOption() int {
+	if config.OwnerOption == "" {
+		return -1
+	}
+	uid, err := strconv.Atoi(config.OwnerOption)
+	if err == nil {
+		return uid
+	}
+	owner, err := user.Lookup(config.OwnerOption)
+	if err != nil {
+		return -1
+	}
+	uid, err = strconv.Atoi(owner.Uid)
+	if err != nil {
+		return -1
+	}
+	return uid
+}
+
+func (config *YAMLFileConfig) groupOption() int {
+	if config.GroupOption == "" {
+		return -1
+	}
+	gid, err := strconv.Atoi(config.GroupOption)
+	if err == nil {
+		return gid
+	}
+	group, err := user.LookupGroup(config.GroupOption)
+	if err != nil {
+		return -1
+	}
+	gid, err = strconv.Atoi(group.Gid)
+	if err != nil {
+		return -1
+	}
+	return gid
+}
+
+func (config *YAMLFileConfig) fallbackOption() Option {
+	switch config.FallbackOption {
+	case "":
+		return WithFallback(nil)
+	case "stdout":
+		return WithFallback(os.Stdout)
+	case "stderr":
+		return WithFallback(os.Stderr)
+	case "discard":
+		return WithFallback(io.Discard)
+	}
+	fallback, err := os.OpenFile(config.FallbackOption, os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaultFileMode)
+	if err != nil {
+		return WithFallback(os.Stderr)
+	}
+	return withOwnedFallback(fallback)
+}
+
+func (config *YAMLFileConfig) fallbackBufferOption() int {
+	fallbackBuffer, err := ParseSize(config.FallbackBufferOption, kilobyte)
+	if err != nil || fallbackBuffer < 0 {
+		return 0
+	}
+	return int(fallbackBuffer)
+}
+
+func (config *YAMLFileConfig) diskFullLevelOption() zerolog.Level {
+	if config.DiskFullLevelOption == "" {
+		return zerolog.Disabled
+	}
+	level, err := zerolog.ParseLevel(config.DiskFullLevelOption)
+	if err != nil {
+		return zerolog.Disabled
+	}
+	return level
+}
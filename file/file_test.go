@@ -0,0 +1,708 @@
+// file_test.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/tdrn-org/go-log/file"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRotateDaily(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	now := time.Date(2024, 1, 1, 23, 59, 0, 0, time.Local)
+	config := &file.YAMLFileConfig{
+		EnabledOption:  true,
+		FilenameOption: filename,
+		RotateOption:   "daily",
+		Clock:          func() time.Time { return now },
+	}
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	_, err := writer.Write([]byte("old\n"))
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	_, err = writer.Write([]byte("new\n"))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("new\n"))
+	require.NoError(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "new\nnew\n", string(content))
+}
+
+func TestRotateOffset(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	now := time.Date(2024, 1, 1, 0, 30, 0, 0, time.Local)
+	writer := file.NewWriter(filename, file.WithRotateInterval(file.RotateDaily), file.WithRotateOffset(3*time.Hour), file.WithClock(func() time.Time { return now }))
+	defer writer.Close()
+	for _, message := range []string{"message 1\n", "message 2\n"} {
+		_, err := writer.Write([]byte(message))
+		require.NoError(t, err)
+		// Passing midnight does not rotate
+		now = now.Add(2 * time.Hour)
+	}
+	require.Equal(t, uint64(0), writer.Stats().Rotations)
+	_, err := writer.Write([]byte("message 3\n"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), writer.Stats().Rotations)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message 3\n", string(content))
+}
+
+func TestRotateHourlyFractionalZone(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	zone := time.FixedZone("IST", 5*60*60+30*60)
+	now := time.Date(2024, 1, 1, 10, 45, 0, 0, zone)
+	writer := file.NewWriter(filename, file.WithRotateInterval(file.RotateHourly), file.WithClock(func() time.Time { return now }))
+	defer writer.Close()
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	// An hourly boundary computed in UTC would be at 11:30 local time
+	now = now.Add(20 * time.Minute)
+	_, err = writer.Write([]byte("message 2\n"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), writer.Stats().Rotations)
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	dir := t.TempDir()
+	config := &file.YAMLFileConfig{
+		EnabledOption:      true,
+		FilenameOption:     filepath.Join(dir, "test.log"),
+		MaxSizeOption:      1024 * 1024,
+		MaxTotalSizeOption: "2",
+	}
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	record := bytes.Repeat([]byte("0123456789abcdef"), 600*1024/16)
+	for range 8 {
+		_, err := writer.Write(record)
+		require.NoError(t, err)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	totalSize := int64(0)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		totalSize += info.Size()
+	}
+	require.LessOrEqual(t, totalSize, int64(2*1024*1024))
+}
+
+func TestMaxTotalSizeForeignFile(t *testing.T) {
+	dir := t.TempDir()
+	foreign := filepath.Join(dir, "test-audit.log")
+	require.NoError(t, os.WriteFile(foreign, bytes.Repeat([]byte{'0'}, 3*1024*1024), 0600))
+	writer := file.NewWriter(filepath.Join(dir, "test.log"), file.WithMaxSize(1024*1024), file.WithMaxTotalSize(2*1024*1024))
+	defer writer.Close()
+	record := bytes.Repeat([]byte("0123456789abcdef"), 600*1024/16)
+	for range 3 {
+		_, err := writer.Write(record)
+		require.NoError(t, err)
+	}
+	require.FileExists(t, foreign)
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	config := &file.YAMLFileConfig{
+		EnabledOption:  true,
+		FilenameOption: filename,
+	}
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(filename, filename+".1"))
+	require.NoError(t, file.Reopen())
+	_, err = writer.Write([]byte("message 2\n"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message 2\n", string(content))
+	content, err = os.ReadFile(filename + ".1")
+	require.NoError(t, err)
+	require.Equal(t, "message 1\n", string(content))
+}
+
+func TestExternalDelete(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	config := &file.YAMLFileConfig{
+		EnabledOption:  true,
+		FilenameOption: filename,
+	}
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filename))
+	time.Sleep(1100 * time.Millisecond)
+	_, err = writer.Write([]byte("message 2\n"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message 2\n", string(content))
+}
+
+func TestBuffer(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	config := &file.YAMLFileConfig{
+		EnabledOption:       true,
+		FilenameOption:      filename,
+		BufferSizeOption:    "4",
+		FlushIntervalOption: "1h",
+		SyncOption:          "interval",
+	}
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	_, err := writer.Write([]byte("message\n"))
+	require.NoError(t, err)
+	require.NoFileExists(t, filename)
+	require.NoError(t, writer.(interface{ Flush() error }).Flush())
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message\n", string(content))
+}
+
+func TestFileMode(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "log")
+	filename := filepath.Join(dir, "test.log")
+	config := &file.YAMLFileConfig{
+		EnabledOption:  true,
+		FilenameOption: filename,
+		FileModeOption: "0640",
+		DirModeOption:  "0750",
+	}
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	_, err := writer.Write([]byte("message\n"))
+	require.NoError(t, err)
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0640), info.Mode().Perm())
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	require.Zero(t, info.Mode().Perm()&^fs.FileMode(0750))
+}
+
+func TestFileModeError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "log")
+	require.NoError(t, os.WriteFile(blocker, nil, 0600))
+	var errs []error
+	writer := file.NewWriter(filepath.Join(blocker, "test.log"), file.WithFileMode(0640, 0750), file.WithOnError(func(err error) {
+		errs = append(errs, err)
+	}))
+	defer writer.Close()
+	_, err := writer.Write([]byte("message\n"))
+	require.Error(t, err)
+	require.Len(t, errs, 2)
+	require.ErrorContains(t, errs[0], "failed to create log directory")
+	require.Equal(t, uint64(2), writer.Stats().WriteErrors)
+}
+
+func TestOnRotate(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	rotated := make(chan [2]string, 1)
+	config := &file.YAMLFileConfig{
+		EnabledOption:  true,
+		FilenameOption: filename,
+		MaxSizeOption:  1024 * 1024,
+		OnRotate: func(oldPath string, newPath string) {
+			rotated <- [2]string{oldPath, newPath}
+		},
+	}
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	record := bytes.Repeat([]byte("0123456789abcdef"), 600*1024/16)
+	_, err := writer.Write(record)
+	require.NoError(t, err)
+	_, err = writer.Write(record)
+	require.NoError(t, err)
+	paths := <-rotated
+	require.FileExists(t, paths[0])
+	require.NotEqual(t, filename, paths[0])
+	require.Equal(t, filename, paths[1])
+}
+
+func TestOnRotateCompress(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	rotated := make(chan string, 1)
+	writer := file.NewWriter(filename, file.WithCompress(true), file.WithOnRotate(func(oldPath string, _ string) {
+		rotated <- oldPath
+	}))
+	defer writer.Close()
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Rotate())
+	oldPath := <-rotated
+	require.True(t, strings.HasSuffix(oldPath, ".log.gz"))
+	require.Equal(t, "message 1\n", readGzip(t, oldPath))
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+	rotated := make(chan string, 1)
+	writer := file.NewWriter(filename, file.WithArchive(archiveDir), file.WithArchiveRetention(0, 2), file.WithOnRotate(func(oldPath string, _ string) {
+		rotated <- oldPath
+	}))
+	defer writer.Close()
+	for _, message := range []string{"message 1\n", "message 2\n", "message 3\n"} {
+		_, err := writer.Write([]byte(message))
+		require.NoError(t, err)
+		require.NoError(t, writer.Rotate())
+		oldPath := <-rotated
+		require.Equal(t, archiveDir, filepath.Dir(oldPath))
+		content, err := os.ReadFile(oldPath)
+		require.NoError(t, err)
+		require.Equal(t, message, string(content))
+		// Backup names have millisecond resolution
+		time.Sleep(2 * time.Millisecond)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	entries, err = os.ReadDir(archiveDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, uint64(0), writer.Stats().ArchiveErrors)
+}
+
+func TestEncryption(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recipients, err := file.ParseRecipients(identity.Recipient().String())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	rotated := make(chan string, 1)
+	writer := file.NewWriter(filename, file.WithCompress(true), file.WithMaxBackups(2), file.WithEncryption(recipients...), file.WithOnRotate(func(oldPath string, _ string) {
+		rotated <- oldPath
+	}))
+	defer writer.Close()
+	for _, message := range []string{"message 1\n", "message 2\n", "message 3\n"} {
+		_, err = writer.Write([]byte(message))
+		require.NoError(t, err)
+		require.NoError(t, writer.Rotate())
+		oldPath := <-rotated
+		require.True(t, strings.HasSuffix(oldPath, ".gz"+file.EncryptedSuffix))
+		encrypted, err := os.Open(oldPath)
+		require.NoError(t, err)
+		decrypted, err := age.Decrypt(encrypted, identity)
+		require.NoError(t, err)
+		decompressed, err := gzip.NewReader(decrypted)
+		require.NoError(t, err)
+		content, err := io.ReadAll(decompressed)
+		require.NoError(t, err)
+		require.NoError(t, encrypted.Close())
+		require.Equal(t, message, string(content))
+		// Backup names have millisecond resolution
+		time.Sleep(2 * time.Millisecond)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for _, entry := range entries {
+		require.True(t, entry.Name() == "test.log" || strings.HasSuffix(entry.Name(), file.EncryptedSuffix))
+	}
+	require.Equal(t, uint64(0), writer.Stats().ArchiveErrors)
+}
+
+func TestEncryptionInvalidRecipient(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	rotated := make(chan string, 1)
+	var errs []error
+	config := &file.YAMLFileConfig{
+		EnabledOption:           true,
+		FilenameOption:          filename,
+		EncryptRecipientsOption: []string{"invalid"},
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+		OnRotate: func(oldPath string, _ string) {
+			rotated <- oldPath
+		},
+	}
+	writer := config.NewWriter().(*file.RotatingFileWriter)
+	defer writer.Close()
+	_, err := writer.Write([]byte("message\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Rotate())
+	oldPath := <-rotated
+	content, err := os.ReadFile(oldPath)
+	require.NoError(t, err)
+	require.Equal(t, "message\n", string(content))
+	require.Len(t, errs, 1)
+	require.Equal(t, uint64(1), writer.Stats().ArchiveErrors)
+}
+
+func TestFallback(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	require.NoError(t, os.WriteFile(blocker, nil, 0600))
+	fallback := filepath.Join(dir, "fallback.log")
+	var errs []error
+	config := &file.YAMLFileConfig{
+		EnabledOption:  true,
+		FilenameOption: filepath.Join(blocker, "test.log"),
+		FallbackOption: fallback,
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	writer := config.NewWriter()
+	_, err := writer.Write([]byte("message\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.(io.Closer).Close())
+	require.Len(t, errs, 1)
+	content, err := os.ReadFile(fallback)
+	require.NoError(t, err)
+	require.Equal(t, "message\n", string(content))
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	writer := file.NewWriter(filename, file.WithBuffer(1024, time.Millisecond))
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	_, err = writer.Write([]byte("message 2\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+	require.ErrorIs(t, writer.Rotate(), os.ErrClosed)
+	require.NoError(t, writer.Flush())
+	require.NoError(t, writer.Close())
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message 1\n", string(content))
+}
+
+func TestFallbackBuffer(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "log")
+	require.NoError(t, os.WriteFile(blocker, nil, 0600))
+	filename := filepath.Join(blocker, "test.log")
+	fallback := &bytes.Buffer{}
+	writer := file.NewWriter(filename, file.WithFallback(fallback), file.WithFallbackBuffer(20))
+	defer writer.Close()
+	for _, message := range []string{"message 1\n", "message 2\n", "message 3\n"} {
+		_, err := writer.Write([]byte(message))
+		require.NoError(t, err)
+	}
+	require.Equal(t, "message 1\n", fallback.String())
+	require.Equal(t, 2, writer.Stats().FallbackBuffered)
+	require.NoError(t, os.Remove(blocker))
+	_, err := writer.Write([]byte("message 4\n"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message 2\nmessage 3\nmessage 4\n", string(content))
+	require.Equal(t, 0, writer.Stats().FallbackBuffered)
+}
+
+func TestFallbackNotClosed(t *testing.T) {
+	dir := t.TempDir()
+	fallback, err := os.Create(filepath.Join(dir, "fallback.log"))
+	require.NoError(t, err)
+	defer fallback.Close()
+	writer := file.NewWriter(filepath.Join(dir, "test.log"), file.WithFallback(fallback))
+	require.NoError(t, writer.Close())
+	_, err = fallback.Write([]byte("message\n"))
+	require.NoError(t, err)
+}
+
+func TestDiskFull(t *testing.T) {
+	_, err := os.Stat("/dev/full")
+	if err != nil {
+		t.Skip("/dev/full not available")
+	}
+	var errs []error
+	config := &file.YAMLFileConfig{
+		EnabledOption:       true,
+		FilenameOption:      "/dev/full",
+		DiskFullLevelOption: "warn",
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	writer := config.NewWriter().(zerolog.LevelWriter)
+	defer writer.(io.Closer).Close()
+	_, err = writer.WriteLevel(zerolog.InfoLevel, []byte("message 1\n"))
+	require.ErrorIs(t, err, syscall.ENOSPC)
+	_, err = writer.WriteLevel(zerolog.InfoLevel, []byte("message 2\n"))
+	require.NoError(t, err)
+	_, err = writer.WriteLevel(zerolog.WarnLevel, []byte("message 3\n"))
+	require.ErrorIs(t, err, syscall.ENOSPC)
+	require.Len(t, errs, 2)
+	stats := writer.(*file.RotatingFileWriter).Stats()
+	require.Equal(t, uint64(2), stats.WriteErrors)
+	require.Equal(t, uint64(1), stats.Dropped)
+	require.ErrorIs(t, stats.LastError, syscall.ENOSPC)
+}
+
+func TestFlushError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "log")
+	require.NoError(t, os.WriteFile(blocker, nil, 0600))
+	filename := filepath.Join(blocker, "test.log")
+	var errs []error
+	writer := file.NewWriter(filename, file.WithBuffer(1024, time.Hour), file.WithOnError(func(err error) {
+		errs = append(errs, err)
+	}))
+	defer writer.Close()
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	require.Error(t, writer.Flush())
+	require.Len(t, errs, 1)
+	require.Equal(t, uint64(1), writer.Stats().WriteErrors)
+	// The buffered data is kept until the flush succeeds
+	require.NoError(t, os.Remove(blocker))
+	_, err = writer.Write([]byte("message 2\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message 1\nmessage 2\n", string(content))
+}
+
+func TestDiskFullBuffered(t *testing.T) {
+	_, err := os.Stat("/dev/full")
+	if err != nil {
+		t.Skip("/dev/full not available")
+	}
+	writer := file.NewWriter("/dev/full", file.WithBuffer(1024, time.Millisecond), file.WithDiskFullLevel(zerolog.WarnLevel))
+	defer writer.Close()
+	_, err = writer.WriteLevel(zerolog.InfoLevel, []byte("message 1\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return errors.Is(writer.Stats().LastError, syscall.ENOSPC)
+	}, time.Second, time.Millisecond)
+	_, err = writer.WriteLevel(zerolog.InfoLevel, []byte("message 2\n"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), writer.Stats().Dropped)
+}
+
+func TestDiskFullPlainFormat(t *testing.T) {
+	_, err := os.Stat("/dev/full")
+	if err != nil {
+		t.Skip("/dev/full not available")
+	}
+	config := &file.YAMLFileConfig{
+		EnabledOption:       true,
+		FilenameOption:      "/dev/full",
+		FormatOption:        "plain",
+		DiskFullLevelOption: "warn",
+	}
+	writer := config.NewWriter().(zerolog.LevelWriter)
+	defer writer.(io.Closer).Close()
+	_, err = writer.WriteLevel(zerolog.InfoLevel, []byte(`{"level":"info","message":"message 1"}`))
+	require.ErrorIs(t, err, syscall.ENOSPC)
+	_, err = writer.WriteLevel(zerolog.InfoLevel, []byte(`{"level":"info","message":"message 2"}`))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), writer.(*file.RotatingFileWriter).Stats().Dropped)
+}
+
+func TestMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	config := &file.YAMLFileConfig{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{enabled: true, max_size: "10B"}`), config))
+	config.FilenameOption = filepath.Join(dir, "test.log")
+	writer := config.NewWriter()
+	defer writer.(io.Closer).Close()
+	for range 2 {
+		_, err := writer.Write([]byte("message\n"))
+		require.NoError(t, err)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoError(t, yaml.Unmarshal([]byte(`{max_size: 10}`), config))
+	require.Equal(t, file.MegabyteSize(10*1024*1024), config.MaxSizeOption)
+	require.Error(t, yaml.Unmarshal([]byte(`{max_size: "10XB"}`), config))
+}
+
+func TestRotatingFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	writer := file.NewWriter(filename, file.WithMaxBackups(1))
+	defer writer.Close()
+	for range 3 {
+		_, err := writer.Write([]byte("message\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Rotate())
+	}
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(dir)
+		return err == nil && len(entries) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestParseSize(t *testing.T) {
+	size, err := file.ParseSize("10", 1024)
+	require.NoError(t, err)
+	require.Equal(t, int64(10*1024), size)
+	size, err = file.ParseSize("10MB", 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(10*1000*1000), size)
+	size, err = file.ParseSize("1.5 GiB", 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(3*1024*1024*1024/2), size)
+	_, err = file.ParseSize("10XB", 1)
+	require.Error(t, err)
+}
+
+func TestPlainFormat(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	config := &file.YAMLFileConfig{
+		EnabledOption:  true,
+		FilenameOption: filename,
+		FormatOption:   "plain",
+	}
+	writer := config.NewWriter()
+	_, err := writer.Write([]byte(`{"level":"info","message":"message","key":"value"}`))
+	require.NoError(t, err)
+	require.NoError(t, writer.(io.Closer).Close())
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "INF message key=value\n", string(content))
+}
+
+func TestHeader(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	writer := file.NewWriter(filename, file.WithHeader(file.DefaultHeader))
+	defer writer.Close()
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Rotate())
+	_, err = writer.Write([]byte("message 2\n"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Regexp(t, `^\{.*"pid":\d+.*"previous":".*test-.*\.log".*"message":"log file opened"\}\nmessage 2\n$`, string(content))
+}
+
+func TestHeaderMaxRecords(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	writer := file.NewWriter(filename, file.WithHeader(file.DefaultHeader), file.WithMaxRecords(3))
+	for _, message := range []string{"message 1\n", "message 2\n"} {
+		_, err := writer.Write([]byte(message))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	writer = file.NewWriter(filename, file.WithHeader(file.DefaultHeader), file.WithMaxRecords(3))
+	defer writer.Close()
+	_, err := writer.Write([]byte("message 3\n"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Regexp(t, `^\{.*"message":"log file opened"\}\nmessage 1\nmessage 2\nmessage 3\n$`, string(content))
+	_, err = writer.Write([]byte("message 4\n"))
+	require.NoError(t, err)
+	content, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Regexp(t, `^\{.*"message":"log file opened"\}\nmessage 4\n$`, string(content))
+	require.Equal(t, uint64(1), writer.Stats().Rotations)
+}
+
+func TestGzip(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log.gz")
+	writer := file.NewWriter(filename, file.WithGzip(true))
+	_, err := writer.Write([]byte("message 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	require.Equal(t, "message 1\n", readGzip(t, filename))
+	require.NoError(t, writer.Close())
+	writer = file.NewWriter(filename, file.WithGzip(true))
+	_, err = writer.Write([]byte("message 2\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.Equal(t, "message 1\nmessage 2\n", readGzip(t, filename))
+}
+
+func TestGzipWriteError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "log")
+	require.NoError(t, os.WriteFile(blocker, nil, 0600))
+	filename := filepath.Join(blocker, "test.log.gz")
+	writer := file.NewWriter(filename, file.WithGzip(true))
+	_, err := writer.Write([]byte("message 1\n"))
+	require.Error(t, err)
+	require.NoError(t, os.Remove(blocker))
+	_, err = writer.Write([]byte("message 2\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.Equal(t, "message 2\n", readGzip(t, filename))
+}
+
+func readGzip(t *testing.T, filename string) string {
+	compressed, err := os.Open(filename)
+	require.NoError(t, err)
+	defer compressed.Close()
+	reader, err := gzip.NewReader(compressed)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	if err != io.ErrUnexpectedEOF {
+		require.NoError(t, err)
+	}
+	return string(content)
+}
+
+func TestMaxRecords(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.log")
+	require.NoError(t, os.WriteFile(filename, []byte("message 1\n"), 0600))
+	writer := file.NewWriter(filename, file.WithMaxRecords(2))
+	defer writer.Close()
+	for _, message := range []string{"message 2\n", "message 3\n", "message 4\n"} {
+		_, err := writer.Write([]byte(message))
+		require.NoError(t, err)
+	}
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "message 3\nmessage 4\n", string(content))
+	stats := writer.Stats()
+	require.Equal(t, uint64(30), stats.BytesWritten)
+	require.Equal(t, uint64(1), stats.Rotations)
+	require.Equal(t, int64(20), stats.FileSize)
+}
@@ -0,0 +1,44 @@
+// header.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var processStart = time.Now()
+
+// DefaultHeader creates a log record describing the running process, suitable as log file header (see [WithHeader]).
+//
+// The record contains the application name and version, the process id, the hostname, the process start time
+// and the name of the previous log file (if any).
+func DefaultHeader(previousPath string) []byte {
+	buffer := &bytes.Buffer{}
+	logger := zerolog.New(buffer).With().Timestamp().Logger()
+	evt := logger.Log().Str("app", filepath.Base(os.Args[0]))
+	buildInfo, ok := debug.ReadBuildInfo()
+	if ok {
+		evt = evt.Str("version", buildInfo.Main.Version)
+	}
+	hostname, err := os.Hostname()
+	if err == nil {
+		evt = evt.Str("hostname", hostname)
+	}
+	evt = evt.Int("pid", os.Getpid()).Time("start", processStart)
+	if previousPath != "" {
+		evt = evt.Str("previous", previousPath)
+	}
+	evt.Msg("log file opened")
+	return buffer.Bytes()
+}
@@ -0,0 +1,78 @@
+// reopen.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var openWriters = make(map[*RotatingFileWriter]struct{})
+var openWritersMutex sync.Mutex
+
+func registerWriter(w *RotatingFileWriter) {
+	openWritersMutex.Lock()
+	defer openWritersMutex.Unlock()
+	openWriters[w] = struct{}{}
+}
+
+func unregisterWriter(w *RotatingFileWriter) {
+	openWritersMutex.Lock()
+	defer openWritersMutex.Unlock()
+	delete(openWriters, w)
+}
+
+// Reopen reopens the log files of all open file writers.
+//
+// Reopen is intended to be invoked after the log files have been moved aside by an external log rotation
+// tool (e.g. logrotate using the create directive).
+func Reopen() error {
+	openWritersMutex.Lock()
+	writers := make([]*RotatingFileWriter, 0, len(openWriters))
+	for w := range openWriters {
+		writers = append(writers, w)
+	}
+	openWritersMutex.Unlock()
+	var errs []error
+	for _, w := range writers {
+		errs = append(errs, w.Reopen())
+	}
+	return errors.Join(errs...)
+}
+
+// ReopenOnSignal invokes [Reopen] whenever one of the given signals is received (defaults to SIGHUP).
+//
+// The returned function stops the signal handling.
+func ReopenOnSignal(signals ...os.Signal) func() {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, signals...)
+	stopChan := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signalChan:
+				Reopen()
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			signal.Stop(signalChan)
+			close(stopChan)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// size.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var sizeUnits = map[string]int64{
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+	"t":   1000 * 1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a human-readable size (e.g. "10MB" or "1GiB") into its number of bytes.
+//
+// Decimal units (KB, MB, GB, TB) are powers of 1000, binary units (KiB, MiB, GiB, TiB) are powers
+// of 1024. A size without unit is multiplied by the given default unit.
+func ParseSize(size string, defaultUnit int64) (int64, error) {
+	trimmed := strings.TrimSpace(size)
+	split := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := trimmed, ""
+	if split >= 0 {
+		number, unit = trimmed[:split], strings.TrimSpace(trimmed[split:])
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s' (cause: %w)", size, err)
+	}
+	multiplier := defaultUnit
+	if unit != "" {
+		var ok bool
+		multiplier, ok = sizeUnits[strings.ToLower(unit)]
+		if !ok {
+			return 0, fmt.Errorf("invalid size unit '%s'", unit)
+		}
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// MegabyteSize is a size in bytes, configured either as a number of megabytes (e.g. 10) or as a human-readable
+// size (e.g. "10MB", see [ParseSize]).
+type MegabyteSize int64
+
+// UnmarshalYAML implements [gopkg.in/yaml.v3.Unmarshaler].
+func (size *MegabyteSize) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	err := node.Decode(&value)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseSize(value, megabyte)
+	if err != nil {
+		return err
+	}
+	*size = MegabyteSize(parsed)
+	return nil
+}
@@ -0,0 +1,839 @@
+// writer.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// RotateInterval defines the interval for time based log file rotation.
+//
+// Rotation boundaries are computed from the wall-clock time of the writer's clock (see [WithClock])
+// and may be shifted by an offset (see [WithRotateOffset]).
+type RotateInterval int
+
+const (
+	// RotateNever disables time based rotation.
+	RotateNever RotateInterval = iota
+	// RotateHourly rotates the log file at the beginning of every hour.
+	RotateHourly
+	// RotateDaily rotates the log file at midnight.
+	RotateDaily
+	// RotateWeekly rotates the log file at midnight between Sunday and Monday.
+	RotateWeekly
+)
+
+func (interval RotateInterval) period() time.Duration {
+	switch interval {
+	case RotateHourly:
+		return time.Hour
+	case RotateDaily:
+		return 24 * time.Hour
+	case RotateWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// next gets the first rotation boundary after the given time.
+func (interval RotateInterval) next(t time.Time, offset time.Duration) time.Time {
+	period := interval.period()
+	if period == 0 {
+		return time.Time{}
+	}
+	offset %= period
+	if offset < 0 {
+		offset += period
+	}
+	for periods := 0; ; periods++ {
+		boundary := interval.boundary(t, periods, offset)
+		if boundary.After(t) {
+			return boundary
+		}
+	}
+}
+
+// boundary gets the rotation boundary the given number of periods after the start of the period
+// containing the given time. The boundary is computed in wall-clock time (honoring time zones with
+// fractional hour offsets as well as daylight saving time changes).
+func (interval RotateInterval) boundary(t time.Time, periods int, offset time.Duration) time.Time {
+	year, month, day := t.Date()
+	hour := 0
+	switch interval {
+	case RotateHourly:
+		hour = t.Hour() + periods
+	case RotateDaily:
+		day += periods
+	case RotateWeekly:
+		day += 7*periods - int((t.Weekday()+6)%7)
+	}
+	// time.Date normalizes the offset (given as nanoseconds) into the wall-clock time
+	return time.Date(year, month, day, hour, 0, 0, int(offset), t.Location())
+}
+
+// SyncMode defines when written log records are synced to disk.
+type SyncMode int
+
+const (
+	// SyncNever leaves syncing to the operating system.
+	SyncNever SyncMode = iota
+	// SyncInterval syncs the log file whenever the write buffer is flushed periodically.
+	SyncInterval
+	// SyncAlways syncs the log file after every write.
+	SyncAlways
+)
+
+// The permissions used by lumberjack for new log files and directories.
+const defaultFileMode fs.FileMode = 0600
+const defaultDirMode fs.FileMode = 0755
+
+// Size based rotation is performed by RotatingFileWriter itself. Therefore lumberjack's size
+// limit (given in megabytes) is set to a limit never reached.
+const megabyte = 1024 * 1024
+const unlimitedLumberjackSize = 1 << 30
+
+// Stats contains the statistics of a file writer.
+type Stats struct {
+	// BytesWritten is the number of bytes written to the log file (before compression).
+	BytesWritten uint64
+	// WriteErrors is the number of failed writes.
+	WriteErrors uint64
+	// Rotations is the number of rotations performed.
+	Rotations uint64
+	// FileSize is the current size of the log file (before compression).
+	FileSize int64
+	// FallbackWrites is the number of log records written to the fallback writer (see [WithFallback]).
+	FallbackWrites uint64
+	// FallbackBuffered is the number of log records currently buffered for replay (see [WithFallbackBuffer]).
+	FallbackBuffered int
+	// Dropped is the number of log records dropped while the disk was full (see [WithDiskFullLevel]) or
+	// evicted from the fallback buffer without a fallback writer (see [WithFallbackBuffer]).
+	Dropped uint64
+	// ArchiveErrors is the number of failures processing rotated log files (see [WithArchive] and [WithEncryption]).
+	ArchiveErrors uint64
+	// LastError is the last error that occurred while writing a log record (if any).
+	LastError error
+}
+
+// RotatingFileWriter writes log records to a file, which is rotated by size and/or time.
+//
+// Rotated log files are named after the log file with the rotation timestamp inserted before the
+// file extension. Use [NewWriter] to create a RotatingFileWriter.
+type RotatingFileWriter struct {
+	mutex              sync.Mutex
+	logger             *lumberjack.Logger
+	clock              func() time.Time
+	maxSize            int64
+	maxTotalSize       int64
+	maxRecords         int64
+	interval           RotateInterval
+	rotateOffset       time.Duration
+	fileMode           fs.FileMode
+	dirMode            fs.FileMode
+	uid                int
+	gid                int
+	onRotate           func(oldPath string, newPath string)
+	archive            *archive
+	encryption         *encryption
+	onError            func(err error)
+	header             func(previousPath string) []byte
+	render             func(p []byte) []byte
+	previous           string
+	fallback           io.Writer
+	fallbackCloser     io.Closer
+	fallbackBuffer     [][]byte
+	fallbackBuffered   int
+	fallbackBufferSize int
+	diskFullLevel      zerolog.Level
+	diskFull           bool
+	diskFullRetryAt    time.Time
+	diskFullDropped    uint64
+	flushEvery         time.Duration
+	syncMode           SyncMode
+	buffer             *writeBuffer
+	gzip               *gzip.Writer
+	streaming          bool
+	stopFlusher        chan struct{}
+	opened             bool
+	closed             bool
+	fileInfo           fs.FileInfo
+	checkAt            time.Time
+	size               int64
+	records            int64
+	rotateAt           time.Time
+	stats              Stats
+}
+
+// diskFullRetryInterval defines how often records below the disk full level are written
+// to probe whether the disk is still full.
+const diskFullRetryInterval = 1 * time.Second
+
+// fileCheckInterval defines how often the log file is checked for having been moved or
+// deleted externally.
+const fileCheckInterval = 1 * time.Second
+
+// Write writes the given log record to the log file.
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel writes the given log record of the given level to the log file.
+//
+// Writing to a closed writer fails with [os.ErrClosed].
+func (w *RotatingFileWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	now := w.clock()
+	if w.diskFull && level < w.diskFullLevel && now.Before(w.diskFullRetryAt) {
+		w.stats.Dropped++
+		w.diskFullDropped++
+		return len(p), nil
+	}
+	var n int
+	err := w.replayFallback()
+	if err == nil {
+		n, err = w.write(p)
+	}
+	if err != nil {
+		return w.writeFallback(p, n, err)
+	}
+	if w.diskFull {
+		// Make sure buffered data actually reaches the disk before reporting its recovery
+		err = w.flush(false)
+		if err != nil {
+			w.failure(err)
+			return n, nil
+		}
+		w.diskFull = false
+		w.write(diskSpaceRecovered(w.diskFullDropped))
+		w.diskFullDropped = 0
+	}
+	return n, nil
+}
+
+// diskSpaceRecovered creates the log record written as soon as the log file's disk is no longer full
+// (see [WithDiskFullLevel]).
+func diskSpaceRecovered(dropped uint64) []byte {
+	buffer := &bytes.Buffer{}
+	logger := zerolog.New(buffer).With().Timestamp().Logger()
+	logger.WithLevel(zerolog.WarnLevel).Uint64("dropped", dropped).Msg("disk space recovered")
+	return buffer.Bytes()
+}
+
+func (w *RotatingFileWriter) writeFallback(p []byte, n int, err error) (int, error) {
+	w.failure(err)
+	if w.fallbackBufferSize > 0 {
+		w.bufferFallback(p)
+		return len(p), nil
+	}
+	if w.fallback == nil {
+		return n, err
+	}
+	w.stats.FallbackWrites++
+	return w.fallback.Write(p)
+}
+
+// bufferFallback buffers the given record for replay (see [WithFallbackBuffer]). If the buffer is full, the
+// oldest buffered records are passed to the fallback writer (if any) or dropped.
+func (w *RotatingFileWriter) bufferFallback(p []byte) {
+	w.fallbackBuffer = append(w.fallbackBuffer, bytes.Clone(p))
+	w.fallbackBuffered += len(p)
+	for w.fallbackBuffered > w.fallbackBufferSize {
+		w.evictFallback()
+	}
+}
+
+func (w *RotatingFileWriter) evictFallback() {
+	p := w.fallbackBuffer[0]
+	w.fallbackBuffer[0] = nil
+	w.fallbackBuffer = w.fallbackBuffer[1:]
+	w.fallbackBuffered -= len(p)
+	if w.fallback == nil {
+		w.stats.Dropped++
+		return
+	}
+	w.stats.FallbackWrites++
+	_, err := w.fallback.Write(p)
+	if err != nil {
+		w.stats.Dropped++
+	}
+}
+
+// replayFallback writes the records buffered while the log file was failing (see [WithFallbackBuffer]).
+func (w *RotatingFileWriter) replayFallback() error {
+	for len(w.fallbackBuffer) > 0 {
+		p := w.fallbackBuffer[0]
+		_, err := w.write(p)
+		if err != nil {
+			return err
+		}
+		w.fallbackBuffer[0] = nil
+		w.fallbackBuffer = w.fallbackBuffer[1:]
+		w.fallbackBuffered -= len(p)
+	}
+	w.fallbackBuffer = nil
+	return nil
+}
+
+// failure records a failed write or flush and enters the disk full mode (see [WithDiskFullLevel]) if
+// it was caused by a full disk.
+func (w *RotatingFileWriter) failure(err error) {
+	w.stats.WriteErrors++
+	w.stats.LastError = err
+	if w.diskFullLevel != zerolog.Disabled && errors.Is(err, syscall.ENOSPC) {
+		w.diskFull = true
+		w.diskFullRetryAt = w.clock().Add(diskFullRetryInterval)
+	}
+	if w.onError != nil {
+		w.onError(err)
+	}
+}
+
+// Stats gets the current statistics of the file writer.
+func (w *RotatingFileWriter) Stats() Stats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	stats := w.stats
+	stats.FileSize = w.size
+	stats.FallbackBuffered = len(w.fallbackBuffer)
+	return stats
+}
+
+func (w *RotatingFileWriter) write(p []byte) (int, error) {
+	now := w.clock()
+	if !w.opened {
+		w.start(now)
+	} else if !now.Before(w.checkAt) {
+		w.checkFile(now)
+	}
+	if w.rotationDue(now, len(p)) {
+		err := w.rotate(now)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if w.size == 0 && w.header != nil {
+		_, err := w.writeRaw(w.renderRecord(w.header(w.previous)))
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.writeRaw(w.renderRecord(p))
+	if n > 0 {
+		w.records++
+	}
+	if err == nil {
+		n = len(p)
+	} else {
+		n = min(n, len(p))
+	}
+	if err == nil && w.syncMode == SyncAlways {
+		err = w.flush(true)
+	}
+	if w.fileInfo == nil {
+		w.fileInfo, _ = os.Stat(w.logger.Filename)
+	}
+	return n, err
+}
+
+func (w *RotatingFileWriter) renderRecord(p []byte) []byte {
+	if w.render == nil {
+		return p
+	}
+	return w.render(p)
+}
+
+func (w *RotatingFileWriter) writeRaw(p []byte) (int, error) {
+	var n int
+	var err error
+	if w.gzip != nil {
+		w.streaming = true
+		n, err = w.gzip.Write(p)
+		if err != nil {
+			w.resetStream()
+		}
+	} else if w.buffer != nil {
+		n, err = w.buffer.Write(p)
+	} else {
+		n, err = w.logger.Write(p)
+	}
+	w.size += int64(n)
+	w.stats.BytesWritten += uint64(n)
+	return n, err
+}
+
+// Flush writes any buffered data to the log file (and syncs it, if configured).
+func (w *RotatingFileWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	err := w.flush(w.syncMode != SyncNever)
+	if err != nil {
+		w.failure(err)
+	}
+	return err
+}
+
+func (w *RotatingFileWriter) flush(sync bool) error {
+	if w.streaming {
+		err := w.gzip.Flush()
+		if err != nil {
+			w.resetStream()
+			return err
+		}
+	}
+	if w.buffer != nil {
+		// On failure the data stays buffered for the next flush
+		err := w.buffer.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	if !sync {
+		return nil
+	}
+	// lumberjack does not expose its file handle, but syncing another handle of the
+	// same file syncs the file's data as well.
+	file, err := os.OpenFile(w.logger.Filename, os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+// finishStream completes the gzip stream (if enabled) of the current log file. Subsequent
+// writes start a new gzip stream.
+func (w *RotatingFileWriter) finishStream() error {
+	if !w.streaming {
+		return nil
+	}
+	w.streaming = false
+	err := w.gzip.Close()
+	w.gzip.Reset(w.streamTarget())
+	return err
+}
+
+// resetStream discards the sticky error state of the gzip stream (and the underlying buffer, as
+// it contains an incomplete gzip stream) after a failed write. Subsequent writes start a new gzip stream.
+func (w *RotatingFileWriter) resetStream() {
+	w.streaming = false
+	if w.buffer != nil {
+		w.buffer.Reset()
+	}
+	w.gzip.Reset(w.streamTarget())
+}
+
+func (w *RotatingFileWriter) streamTarget() io.Writer {
+	if w.buffer != nil {
+		return w.buffer
+	}
+	return w.logger
+}
+
+func (w *RotatingFileWriter) start(now time.Time) {
+	registerWriter(w)
+	w.startFlusher()
+	w.open(now)
+}
+
+func (w *RotatingFileWriter) startFlusher() {
+	if w.stopFlusher != nil || (w.buffer == nil && w.gzip == nil && w.syncMode != SyncInterval) {
+		return
+	}
+	stopFlusher := make(chan struct{})
+	w.stopFlusher = stopFlusher
+	go func() {
+		ticker := time.NewTicker(w.flushEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.mutex.Lock()
+				if !w.closed {
+					err := w.flush(w.syncMode == SyncInterval)
+					if err != nil {
+						w.failure(err)
+					}
+				}
+				w.mutex.Unlock()
+			case <-stopFlusher:
+				return
+			}
+		}
+	}()
+}
+
+// checkFile reopens the log file if it has been moved or deleted since it was opened.
+func (w *RotatingFileWriter) checkFile(now time.Time) {
+	w.checkAt = now.Add(fileCheckInterval)
+	if w.fileInfo == nil {
+		return
+	}
+	info, err := os.Stat(w.logger.Filename)
+	if err == nil && os.SameFile(info, w.fileInfo) {
+		return
+	}
+	w.finishStream()
+	w.flush(false)
+	w.logger.Close()
+	w.open(now)
+}
+
+func (w *RotatingFileWriter) open(now time.Time) {
+	w.opened = true
+	w.fileInfo = nil
+	w.checkAt = now.Add(fileCheckInterval)
+	w.size = 0
+	w.records = 0
+	w.rotateAt = w.interval.next(now, w.rotateOffset)
+	info, err := os.Stat(w.logger.Filename)
+	if err == nil {
+		// Take over the state of an already existing log file
+		w.size = info.Size()
+		w.rotateAt = w.interval.next(info.ModTime(), w.rotateOffset)
+		if w.maxRecords > 0 && w.gzip == nil {
+			w.records = countRecords(w.logger.Filename)
+			if w.header != nil && w.records > 0 {
+				// The first record is the header, which is not counted
+				w.records--
+			}
+		}
+	} else {
+		// Writing may still succeed (e.g. if only setting the owner failed), hence the error is
+		// reported, but does not fail the write
+		err = w.create()
+		if err != nil {
+			w.failure(err)
+		}
+	}
+}
+
+// countRecords counts the records (lines) of an already existing log file.
+func countRecords(filename string) int64 {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	records := int64(0)
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buffer)
+		records += int64(bytes.Count(buffer[:n], []byte{'\n'}))
+		if err != nil {
+			return records
+		}
+	}
+}
+
+// create creates the log file with the configured permissions and owner. On rotation
+// lumberjack takes over the permissions and owner of the rotated file.
+func (w *RotatingFileWriter) create() error {
+	if w.fileMode == 0 && w.dirMode == 0 && w.uid < 0 && w.gid < 0 {
+		return nil
+	}
+	dirMode := w.dirMode
+	if dirMode == 0 {
+		dirMode = defaultDirMode
+	}
+	err := os.MkdirAll(filepath.Dir(w.logger.Filename), dirMode)
+	if err != nil {
+		return fmt.Errorf("failed to create log directory '%s' (cause: %w)", filepath.Dir(w.logger.Filename), err)
+	}
+	fileMode := w.fileMode
+	if fileMode == 0 {
+		fileMode = defaultFileMode
+	}
+	file, err := os.OpenFile(w.logger.Filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fileMode)
+	if errors.Is(err, fs.ErrExist) {
+		// Created concurrently, leave it as is
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to create log file '%s' (cause: %w)", w.logger.Filename, err)
+	}
+	file.Close()
+	// Enforce the file mode regardless of the umask
+	err = os.Chmod(w.logger.Filename, fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to set mode of log file '%s' (cause: %w)", w.logger.Filename, err)
+	}
+	if w.uid >= 0 || w.gid >= 0 {
+		err = os.Chown(w.logger.Filename, w.uid, w.gid)
+		if err != nil {
+			return fmt.Errorf("failed to set owner of log file '%s' (cause: %w)", w.logger.Filename, err)
+		}
+	}
+	return nil
+}
+
+func (w *RotatingFileWriter) rotationDue(now time.Time, writeLen int) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.maxSize > 0 && w.size+int64(writeLen) > w.maxSize {
+		return true
+	}
+	if w.maxRecords > 0 && w.records >= w.maxRecords {
+		return true
+	}
+	return w.interval != RotateNever && !now.Before(w.rotateAt)
+}
+
+func (w *RotatingFileWriter) rotate(now time.Time) error {
+	err := errors.Join(w.finishStream(), w.flush(false))
+	if err != nil {
+		return err
+	}
+	err = w.logger.Rotate()
+	if err != nil {
+		return err
+	}
+	w.stats.Rotations++
+	w.fileInfo = nil
+	w.size = 0
+	w.records = 0
+	w.rotateAt = w.interval.next(now, w.rotateOffset)
+	if w.maxTotalSize > 0 {
+		w.prune()
+	}
+	if w.onRotate == nil && w.archive == nil && w.encryption == nil && w.header == nil {
+		return nil
+	}
+	w.previous = w.latestBackup()
+	if (w.onRotate != nil || w.archive != nil || w.encryption != nil) && w.previous != "" {
+		go w.rotated(w.previous, w.logger.Filename, w.logger.Compress)
+	}
+	return nil
+}
+
+// compressWaitTimeout defines how long to wait for lumberjack to compress a rotated log file.
+const compressWaitTimeout = 1 * time.Minute
+
+// compressPollInterval defines how often to check whether lumberjack has compressed a rotated log file.
+const compressPollInterval = 10 * time.Millisecond
+
+// rotated invokes the rotation hook as soon as lumberjack has finished processing the rotated log file.
+//
+// lumberjack compresses rotated log files in the background without signaling completion. Therefore
+// the rotated file is polled until it has been replaced by its compressed version.
+func (w *RotatingFileWriter) rotated(rotatedPath string, path string, compress bool) {
+	if compress {
+		rotatedPath = awaitCompression(rotatedPath, compressWaitTimeout)
+	}
+	var err error
+	if w.encryption != nil {
+		rotatedPath, err = w.encryption.encrypt(rotatedPath)
+		if err != nil {
+			w.archiveFailure(err)
+		}
+		if w.archive == nil {
+			// lumberjack's retention does not recognize encrypted backups
+			err = pruneBackups(filepath.Dir(path), path, w.logger.MaxAge, w.logger.MaxBackups, time.Now())
+			if err != nil {
+				w.archiveFailure(err)
+			}
+		}
+	}
+	if w.archive != nil {
+		rotatedPath, err = w.archive.store(rotatedPath, path, time.Now())
+		if err != nil {
+			w.archiveFailure(err)
+		}
+	}
+	if w.onRotate != nil {
+		w.onRotate(rotatedPath, path)
+	}
+}
+
+func (w *RotatingFileWriter) archiveFailure(err error) {
+	w.mutex.Lock()
+	w.stats.ArchiveErrors++
+	w.stats.LastError = err
+	w.mutex.Unlock()
+	if w.onError != nil {
+		w.onError(err)
+	}
+}
+
+// awaitCompression waits until the given rotated log file has been compressed and gets the name of the
+// compressed file. If compression does not finish in time, the name of the uncompressed file is returned.
+func awaitCompression(rotatedPath string, timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := os.Stat(rotatedPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return rotatedPath
+		}
+		time.Sleep(compressPollInterval)
+	}
+	compressedPath := rotatedPath + ".gz"
+	_, err := os.Stat(compressedPath)
+	if err != nil {
+		// Removed by lumberjack (due to the configured retention) or compression failed
+		return rotatedPath
+	}
+	return compressedPath
+}
+
+func (w *RotatingFileWriter) latestBackup() string {
+	latest := ""
+	ext := filepath.Ext(w.logger.Filename)
+	for _, backup := range w.backups() {
+		// Backup names contain a sortable timestamp
+		name := backup.Name()
+		if strings.HasSuffix(name, ext) && name > latest {
+			latest = name
+		}
+	}
+	if latest == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(w.logger.Filename), latest)
+}
+
+// prune removes the oldest backups until the total size of the backups and a fully
+// written log file is below the configured limit.
+func (w *RotatingFileWriter) prune() {
+	backups := w.backups()
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime().After(backups[j].ModTime())
+	})
+	totalSize := max(w.size, w.maxSize)
+	dir := filepath.Dir(w.logger.Filename)
+	for _, backup := range backups {
+		totalSize += backup.Size()
+		if totalSize > w.maxTotalSize {
+			os.Remove(filepath.Join(dir, backup.Name()))
+		}
+	}
+}
+
+// backupTimeFormat defines the timestamp format used by lumberjack for naming backups.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// backups gets the rotated log files of the log file. Like lumberjack itself, only files named
+// after the log file with a valid rotation timestamp are considered.
+func (w *RotatingFileWriter) backups() []fs.FileInfo {
+	dir := filepath.Dir(w.logger.Filename)
+	prefix, ext := backupPattern(w.logger.Filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	backups := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		_, ok := backupTime(entry.Name(), prefix, ext)
+		if !entry.Type().IsRegular() || !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err == nil {
+			backups = append(backups, info)
+		}
+	}
+	return backups
+}
+
+// backupPattern gets the prefix and extension of the backup names of the given log file.
+func backupPattern(filename string) (string, string) {
+	name := filepath.Base(filename)
+	ext := filepath.Ext(name)
+	return name[:len(name)-len(ext)] + "-", ext
+}
+
+// backupTime gets the rotation timestamp of the given backup name (see [backupPattern]).
+func backupTime(name string, prefix string, ext string) (time.Time, bool) {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, EncryptedSuffix), ".gz")
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) || len(name) < len(prefix)+len(ext) {
+		return time.Time{}, false
+	}
+	timestamp, err := time.Parse(backupTimeFormat, name[len(prefix):len(name)-len(ext)])
+	return timestamp, err == nil
+}
+
+// Rotate rotates the log file immediately.
+func (w *RotatingFileWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	now := w.clock()
+	if !w.opened {
+		w.start(now)
+	}
+	return w.rotate(now)
+}
+
+// Reopen closes the log file, causing it to be reopened on the next write.
+func (w *RotatingFileWriter) Reopen() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.opened = false
+	return errors.Join(w.finishStream(), w.flush(false), w.logger.Close())
+}
+
+// Close flushes any buffered data and closes the log file.
+//
+// Once closed, the writer cannot be used anymore. Closing it again has no effect.
+func (w *RotatingFileWriter) Close() error {
+	unregisterWriter(w)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.stopFlusher != nil {
+		close(w.stopFlusher)
+		w.stopFlusher = nil
+	}
+	w.opened = false
+	err := w.replayFallback()
+	for len(w.fallbackBuffer) > 0 {
+		w.evictFallback()
+	}
+	err = errors.Join(err, w.finishStream(), w.flush(w.syncMode != SyncNever), w.logger.Close())
+	if w.fallbackCloser != nil {
+		err = errors.Join(err, w.fallbackCloser.Close())
+		w.fallback = io.Discard
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+// format.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package log
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+var valueFormatters sync.Map
+var defaultInterfaceMarshalFunc = zerolog.InterfaceMarshalFunc
+
+// RegisterValueFormatter registers a formatter for values of type T.
+//
+// The formatter is used whenever a value of type T is logged via [github.com/rs/zerolog.Event.Interface]
+// (or one of the other functions logging arbitrary values) and replaces the default JSON marshaling
+// of the value with the returned string. A previously registered formatter for the same type is replaced.
+func RegisterValueFormatter[T any](format func(T) string) {
+	valueFormatters.Store(reflect.TypeFor[T](), func(v any) string {
+		return format(v.(T))
+	})
+}
+
+// UnregisterValueFormatter removes the formatter for values of type T.
+func UnregisterValueFormatter[T any]() {
+	valueFormatters.Delete(reflect.TypeFor[T]())
+}
+
+func marshalInterface(v any) ([]byte, error) {
+	if v != nil {
+		format, found := valueFormatters.Load(reflect.TypeOf(v))
+		if found {
+			return defaultInterfaceMarshalFunc(format.(func(any) string)(v))
+		}
+	}
+	return defaultInterfaceMarshalFunc(v)
+}
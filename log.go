@@ -9,8 +9,11 @@
 package log
 
 import (
+	"context"
+	"errors"
 	"io"
-	"log"
+	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
@@ -25,20 +28,41 @@ var defaultLevel = zerolog.WarnLevel
 var defaultTimeFieldFormat = time.RFC3339
 var rootLogger = defaultLogger
 var rootLoggerMutex sync.RWMutex
+var rootCloser io.Closer
 
 func newDefaultLogger(w io.Writer) *zerolog.Logger {
 	return NewLogger(w, true)
 }
 
+// FieldProvider provides a field to add to a log record at the time the record is logged.
+//
+// The given context is the one attached to the log record (if any).
+type FieldProvider func(ctx context.Context) (string, any)
+
 // NewLogger creates a new [github.com/rs/zerolog.Logger] for the given options.
-func NewLogger(w io.Writer, timestamp bool) *zerolog.Logger {
+//
+// The optional field providers are invoked for every log record and their fields are added to the record.
+func NewLogger(w io.Writer, timestamp bool, providers ...FieldProvider) *zerolog.Logger {
 	logger := zerolog.New(w)
 	if timestamp {
 		logger = logger.With().Timestamp().Logger()
 	}
+	if len(providers) > 0 {
+		logger = logger.Hook(fieldProviderHook(providers))
+	}
 	return &logger
 }
 
+type fieldProviderHook []FieldProvider
+
+func (hook fieldProviderHook) Run(e *zerolog.Event, level zerolog.Level, message string) {
+	ctx := e.GetCtx()
+	for _, provider := range hook {
+		key, value := provider(ctx)
+		e.Interface(key, value)
+	}
+}
+
 // RootLogger gets the current root logger.
 func RootLogger() *zerolog.Logger {
 	rootLoggerMutex.RLock()
@@ -64,8 +88,7 @@ func SetRootLogger(logger *zerolog.Logger, level zerolog.Level, timeFieldFormat
 			rootLogger.Info().Msg("root logger re-set")
 		}
 	}
-	log.SetFlags(0)
-	log.SetOutput(rootLogger)
+	RedirectStdLog(rootLogger)
 	setLevel(level)
 	setTimeFieldFormat(timeFieldFormat)
 	return rootLogger
@@ -82,8 +105,38 @@ type Config interface {
 }
 
 // SetRootLoggerFromConfig sets a new root logger as well as log level and time field format using a [github.com/tdrn-org/go-log/Config] interface.
+//
+// If the config also implements [io.Closer], it is closed by a subsequent call to [Close] or as soon as
+// the root logger is set from another config.
 func SetRootLoggerFromConfig(config Config) *zerolog.Logger {
-	return SetRootLogger(config.Logger(), config.Level(), config.TimeFieldFormat())
+	logger := SetRootLogger(config.Logger(), config.Level(), config.TimeFieldFormat())
+	closer, _ := config.(io.Closer)
+	rootLoggerMutex.Lock()
+	previousCloser := rootCloser
+	rootCloser = closer
+	rootLoggerMutex.Unlock()
+	if previousCloser != nil && previousCloser != closer {
+		err := previousCloser.Close()
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to close previous log config")
+		}
+	}
+	return logger
+}
+
+// Close resets the root logger to it's default and closes the config used to set it (see [SetRootLoggerFromConfig]).
+//
+// Close should be invoked on shutdown to flush and release the root logger's writers.
+func Close() error {
+	ResetRootLogger()
+	rootLoggerMutex.Lock()
+	closer := rootCloser
+	rootCloser = nil
+	rootLoggerMutex.Unlock()
+	if closer == nil {
+		return nil
+	}
+	return closer.Close()
 }
 
 // SetLevel sets the log level.
@@ -116,14 +169,61 @@ func setTimeFieldFormat(timeFieldFormat string) {
 	}
 }
 
+// Clock provides the current time for log record timestamps.
+type Clock interface {
+	// Now gets the current time.
+	Now() time.Time
+}
+
+// ClockFunc adapts an ordinary function to the [Clock] interface.
+type ClockFunc func() time.Time
+
+// Now gets the current time by invoking the function.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock provides the local system time.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// UTCClock provides the system time in UTC.
+var UTCClock Clock = ClockFunc(func() time.Time { return time.Now().UTC() })
+
+// SetClock sets the clock used for log record timestamps.
+func SetClock(clock Clock) {
+	rootLoggerMutex.Lock()
+	defer rootLoggerMutex.Unlock()
+	zerolog.TimestampFunc = clock.Now
+}
+
+// CallerWithFunction renders the caller field of a log record as source location followed by the
+// caller's function name (e.g. "/src/main.go:42 main.run").
+//
+// Assign it to [github.com/rs/zerolog.CallerMarshalFunc] to make the function name available to the
+// console writer (see [github.com/tdrn-org/go-log/console.WithFunctionName]).
+func CallerWithFunction(pc uintptr, file string, line int) string {
+	caller := file + ":" + strconv.Itoa(line)
+	function := runtime.FuncForPC(pc)
+	if function == nil {
+		return caller
+	}
+	return caller + " " + function.Name()
+}
+
 // YAMLConfig supports a YAML file based logging configuration.
 type YAMLConfig struct {
 	LevelOption           string                    `yaml:"level"`
 	TimestampOption       bool                      `yaml:"timestamp"`
+	CallerOption          bool                      `yaml:"caller"`
+	CallerFunctionOption  bool                      `yaml:"callerFunction"`
 	TimeFieldFormatOption string                    `yaml:"timeFieldFormat"`
+	MaxRecordSizeOption   int                       `yaml:"maxRecordSize"`
+	SortFieldsOption      bool                      `yaml:"sortFields"`
 	Console               console.YAMLConsoleConfig `yaml:"console"`
 	File                  file.YAMLFileConfig       `yaml:"file"`
 	Syslog                syslog.YAMLSyslogConfig   `yaml:"syslog"`
+	FieldProviders        []FieldProvider           `yaml:"-"`
+	closers               []io.Closer
 }
 
 func (config *YAMLConfig) Logger() *zerolog.Logger {
@@ -137,18 +237,48 @@ func (config *YAMLConfig) Logger() *zerolog.Logger {
 	if config.Syslog.EnabledOption {
 		writers = append(writers, config.Syslog.NewWriter())
 	}
+	for _, writer := range writers {
+		closer, ok := writer.(io.Closer)
+		if ok {
+			config.closers = append(config.closers, closer)
+		}
+	}
 	var logger *zerolog.Logger
 	switch len(writers) {
 	case 0:
 		logger = defaultLogger
 	case 1:
-		logger = NewLogger(writers[0], config.TimestampOption)
+		logger = NewLogger(config.limitWriter(writers[0]), config.TimestampOption, config.FieldProviders...)
 	default:
-		logger = NewLogger(zerolog.MultiLevelWriter(writers...), config.TimestampOption)
+		logger = NewLogger(config.limitWriter(zerolog.MultiLevelWriter(writers...)), config.TimestampOption, config.FieldProviders...)
+	}
+	if len(writers) > 0 && config.CallerOption {
+		if config.CallerFunctionOption {
+			zerolog.CallerMarshalFunc = CallerWithFunction
+		}
+		callerLogger := logger.With().Caller().Logger()
+		logger = &callerLogger
 	}
 	return logger
 }
 
+// Close closes all writers created by previous invocations of [YAMLConfig.Logger].
+func (config *YAMLConfig) Close() error {
+	var errs []error
+	for _, closer := range config.closers {
+		errs = append(errs, closer.Close())
+	}
+	config.closers = nil
+	return errors.Join(errs...)
+}
+
+func (config *YAMLConfig) limitWriter(w io.Writer) io.Writer {
+	if config.SortFieldsOption {
+		w = NewSortedFieldsWriter(w)
+	}
+	return NewSizeLimitWriter(w, config.MaxRecordSizeOption, DefaultTruncateMarker)
+}
+
 func (config *YAMLConfig) Level() zerolog.Level {
 	level, err := zerolog.ParseLevel(config.LevelOption)
 	if err != nil {
@@ -163,4 +293,5 @@ func (config *YAMLConfig) TimeFieldFormat() string {
 
 func init() {
 	zerolog.SetGlobalLevel(defaultLevel)
+	zerolog.InterfaceMarshalFunc = marshalInterface
 }
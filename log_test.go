@@ -8,7 +8,16 @@
 package log_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	stdlog "log"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,6 +25,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tdrn-org/go-log"
 	"github.com/tdrn-org/go-log/console"
+	"github.com/tdrn-org/go-log/file"
 	"gopkg.in/yaml.v3"
 )
 
@@ -58,3 +68,153 @@ func TestConfig(t *testing.T) {
 	require.NoError(t, err)
 	log.SetRootLoggerFromConfig(&config)
 }
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	config1 := newBufferedFileConfig(filepath.Join(dir, "test1.log"))
+	log.SetRootLoggerFromConfig(config1)
+	log.RootLogger().Info().Msg("message 1")
+	require.NoFileExists(t, config1.File.FilenameOption)
+	config2 := newBufferedFileConfig(filepath.Join(dir, "test2.log"))
+	log.SetRootLoggerFromConfig(config2)
+	require.FileExists(t, config1.File.FilenameOption)
+	log.RootLogger().Info().Msg("message 2")
+	require.NoFileExists(t, config2.File.FilenameOption)
+	require.NoError(t, log.Close())
+	content, err := os.ReadFile(config2.File.FilenameOption)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"message":"message 2"`)
+	require.NoError(t, log.Close())
+}
+
+func newBufferedFileConfig(filename string) *log.YAMLConfig {
+	return &log.YAMLConfig{
+		LevelOption: "info",
+		File: file.YAMLFileConfig{
+			EnabledOption:       true,
+			FilenameOption:      filename,
+			BufferSizeOption:    "4",
+			FlushIntervalOption: "1h",
+		},
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	log.RedirectStdLog(log.NewLogger(buffer, false))
+	defer log.ResetRootLogger()
+	stdlog.Print("ERROR: error message")
+	require.Equal(t, `{"level":"error","message":"error message"}`+"\n", buffer.String())
+	buffer.Reset()
+	stdlog.Print("plain message")
+	require.Equal(t, `{"message":"plain message"}`+"\n", buffer.String())
+}
+
+func TestLevelWriter(t *testing.T) {
+	_ = log.ResetRootLogger()
+	buffer := &bytes.Buffer{}
+	writer := log.NewLevelWriter(log.NewLogger(buffer, false), zerolog.ErrorLevel)
+	_, err := writer.Write([]byte("[WARN] line 1\nline 2\ntime=now level=error line"))
+	require.NoError(t, err)
+	require.Equal(t, `{"level":"warn","message":"line 1"}`+"\n"+`{"level":"error","message":"line 2"}`+"\n", buffer.String())
+	buffer.Reset()
+	err = writer.Close()
+	require.NoError(t, err)
+	require.Equal(t, `{"level":"error","message":"time=now level=error line"}`+"\n", buffer.String())
+}
+
+func TestSetClock(t *testing.T) {
+	_ = log.ResetRootLogger()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	log.SetClock(log.ClockFunc(func() time.Time { return now }))
+	defer log.SetClock(log.SystemClock)
+	buffer := &bytes.Buffer{}
+	log.NewLogger(buffer, true).Warn().Msg("message")
+	require.Equal(t, `{"level":"warn","time":"2024-01-01T12:00:00Z","message":"message"}`+"\n", buffer.String())
+}
+
+func TestRegisterValueFormatter(t *testing.T) {
+	_ = log.ResetRootLogger()
+	log.RegisterValueFormatter(func(ip net.IP) string { return "ip:" + ip.String() })
+	defer log.UnregisterValueFormatter[net.IP]()
+	buffer := &bytes.Buffer{}
+	log.NewLogger(buffer, false).Warn().Interface("ip", net.IPv4(127, 0, 0, 1)).Interface("other", []int{1}).Msg("message")
+	require.Equal(t, `{"level":"warn","ip":"ip:127.0.0.1","other":[1],"message":"message"}`+"\n", buffer.String())
+}
+
+func TestSizeLimitWriter(t *testing.T) {
+	_ = log.ResetRootLogger()
+	buffer := &bytes.Buffer{}
+	logger := log.NewLogger(log.NewSizeLimitWriter(buffer, 48, log.DefaultTruncateMarker), false)
+	logger.Warn().Str("key", "value").Msg("message")
+	require.Equal(t, `{"level":"warn","message":"message"}`+"\n", buffer.String())
+	buffer.Reset()
+	logger.Warn().Msg("a very long message exceeding the limit")
+	require.Equal(t, `{"level":"warn","message":"a very long mes…"}`+"\n", buffer.String())
+	require.LessOrEqual(t, buffer.Len(), 48)
+}
+
+func TestSizeLimitWriterFieldOrder(t *testing.T) {
+	_ = log.ResetRootLogger()
+	buffer := &bytes.Buffer{}
+	logger := log.NewLogger(log.NewSizeLimitWriter(buffer, 80, log.DefaultTruncateMarker), false)
+	logger.Warn().Str("z", "<a&b>").Err(errors.New("failure")).Str("large", strings.Repeat("x", 40)).Msg("message")
+	require.Equal(t, `{"level":"warn","z":"<a&b>","error":"failure","message":"message"}`+"\n", buffer.String())
+	buffer.Reset()
+	logger.Warn().Str("large", strings.Repeat("x", 40)).Err(errors.New(strings.Repeat("e", 20))).Msg("message")
+	require.Equal(t, `{"level":"warn","error":"eeeeeeeeeeeeeeeeeeee","message":"message"}`+"\n", buffer.String())
+}
+
+func TestSortedFieldsWriter(t *testing.T) {
+	_ = log.ResetRootLogger()
+	buffer := &bytes.Buffer{}
+	logger := log.NewLogger(log.NewSortedFieldsWriter(buffer), false)
+	logger.Warn().Str("b", "2").Dict("group", zerolog.Dict().Int("y", 2).Int("x", 1)).Str("a", "<1>").Msg("message")
+	require.Equal(t, `{"level":"warn","group":{"x":1,"y":2},"a":"<1>","b":"2","message":"message"}`+"\n", buffer.String())
+}
+
+func TestFieldProvider(t *testing.T) {
+	_ = log.ResetRootLogger()
+	type ctxKey struct{}
+	provider := func(ctx context.Context) (string, any) {
+		return "request", ctx.Value(ctxKey{})
+	}
+	buffer := &bytes.Buffer{}
+	logger := log.NewLogger(buffer, false, provider)
+	logger.Warn().Ctx(context.WithValue(context.Background(), ctxKey{}, "id")).Msg("message")
+	require.Equal(t, `{"level":"warn","request":"id","message":"message"}`+"\n", buffer.String())
+}
+
+func TestCallerWithFunction(t *testing.T) {
+	_ = log.ResetRootLogger()
+	callerMarshalFunc := zerolog.CallerMarshalFunc
+	zerolog.CallerMarshalFunc = log.CallerWithFunction
+	defer func() { zerolog.CallerMarshalFunc = callerMarshalFunc }()
+	buffer := &bytes.Buffer{}
+	logger := log.NewLogger(buffer, false).With().Caller().Logger()
+	logger.Warn().Msg("message")
+	require.Regexp(t, `"caller":"[^"]*log_test\.go:\d+ github\.com/tdrn-org/go-log_test\.TestCallerWithFunction"`, buffer.String())
+}
+
+func TestSyncWriter(t *testing.T) {
+	_ = log.ResetRootLogger()
+	buffer := &bytes.Buffer{}
+	writer := log.SyncWriter(buffer)
+	loggers := []*zerolog.Logger{log.NewLogger(writer, false), log.NewLogger(writer, true)}
+	var wg sync.WaitGroup
+	for _, logger := range loggers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				logger.Warn().Str("key", "value").Msg("message")
+			}
+		}()
+	}
+	wg.Wait()
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	require.Len(t, lines, 200)
+	for _, line := range lines {
+		require.True(t, json.Valid([]byte(line)), line)
+	}
+}
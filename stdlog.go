@@ -0,0 +1,76 @@
+// stdlog.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package log
+
+import (
+	"log"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// RedirectStdLog redirects the output of the standard library's [log] package to the given logger.
+//
+// Log lines starting with a level prefix (e.g. "ERROR:" or "WARN:") are logged with the
+// corresponding level. All other lines are logged without level.
+func RedirectStdLog(logger *zerolog.Logger) {
+	log.SetFlags(0)
+	log.SetOutput(&stdLogWriter{logger: logger})
+}
+
+type stdLogWriter struct {
+	logger *zerolog.Logger
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	level, msg := detectLevel(strings.TrimRight(string(p), "\r\n"), zerolog.NoLevel)
+	w.logger.WithLevel(level).Msg(msg)
+	return len(p), nil
+}
+
+var levelPrefixes = []struct {
+	prefix string
+	level  zerolog.Level
+}{
+	{"TRACE:", zerolog.TraceLevel},
+	{"DEBUG:", zerolog.DebugLevel},
+	{"INFO:", zerolog.InfoLevel},
+	{"WARN:", zerolog.WarnLevel},
+	{"WARNING:", zerolog.WarnLevel},
+	{"ERROR:", zerolog.ErrorLevel},
+	{"FATAL:", zerolog.FatalLevel},
+	{"PANIC:", zerolog.PanicLevel},
+}
+
+func detectLevel(line string, defaultLevel zerolog.Level) (zerolog.Level, string) {
+	for _, levelPrefix := range levelPrefixes {
+		prefixLen := len(levelPrefix.prefix)
+		if len(line) >= prefixLen && strings.EqualFold(line[:prefixLen], levelPrefix.prefix) {
+			return levelPrefix.level, strings.TrimLeft(line[prefixLen:], " \t")
+		}
+		bracketPrefix := "[" + levelPrefix.prefix[:prefixLen-1] + "]"
+		bracketPrefixLen := len(bracketPrefix)
+		if len(line) >= bracketPrefixLen && strings.EqualFold(line[:bracketPrefixLen], bracketPrefix) {
+			return levelPrefix.level, strings.TrimLeft(line[bracketPrefixLen:], " \t")
+		}
+	}
+	for _, field := range strings.Fields(line) {
+		value, found := strings.CutPrefix(field, "level=")
+		if !found {
+			continue
+		}
+		level, err := zerolog.ParseLevel(strings.ToLower(strings.Trim(value, `"`)))
+		if err == nil && level != zerolog.NoLevel {
+			return level, line
+		}
+		if strings.EqualFold(value, "warning") {
+			return zerolog.WarnLevel, line
+		}
+	}
+	return defaultLevel, line
+}
@@ -0,0 +1,96 @@
+// backoff.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package syslog
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// ErrUnavailable indicates that a log record has been dropped, because the syslog server is currently unavailable.
+var ErrUnavailable = errors.New("syslog server unavailable")
+
+// ErrPermanentFailure indicates that a log record has been dropped, because the syslog writer has given up
+// connecting to the syslog server (see [WithRetryLimit]).
+var ErrPermanentFailure = errors.New("syslog server permanently unavailable")
+
+const (
+	// DefaultBackoffInitial defines the default delay before retrying a failed syslog connection.
+	DefaultBackoffInitial = 1 * time.Second
+	// DefaultBackoffMax defines the default maximum delay before retrying a failed syslog connection.
+	DefaultBackoffMax = 1 * time.Minute
+)
+
+type backoff struct {
+	initial      time.Duration
+	max          time.Duration
+	maxAttempts  int
+	maxTime      time.Duration
+	failures     int
+	firstFailure time.Time
+	retryAt      time.Time
+}
+
+func (b *backoff) ready(now time.Time) bool {
+	return b.failures == 0 || !now.Before(b.retryAt)
+}
+
+func (b *backoff) success() {
+	b.failures = 0
+}
+
+func (b *backoff) failure(now time.Time) {
+	if b.failures == 0 {
+		b.firstFailure = now
+	}
+	b.failures++
+	delay := b.initial << min(b.failures-1, 30)
+	if delay <= 0 || delay > b.max {
+		delay = b.max
+	}
+	if delay > 1 {
+		delay = delay/2 + rand.N(delay/2)
+	}
+	b.retryAt = now.Add(delay)
+}
+
+// exhausted checks whether the retry budget (see [WithRetryLimit]) has been used up.
+func (b *backoff) exhausted(now time.Time) bool {
+	if b.failures == 0 {
+		return false
+	}
+	return (b.maxAttempts > 0 && b.failures >= b.maxAttempts) || (b.maxTime > 0 && now.Sub(b.firstFailure) >= b.maxTime)
+}
+
+// isPermanentError checks whether the given connection error cannot be resolved by retrying (e.g. due to
+// an unknown network, an invalid address or an unknown host).
+func isPermanentError(err error) bool {
+	var unknownNetworkErr net.UnknownNetworkError
+	var addrErr *net.AddrError
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &unknownNetworkErr), errors.As(err, &addrErr):
+		return true
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsNotFound
+	}
+	return false
+}
+
+// isRecordError checks whether the given error is caused by the record itself (e.g. due to its size)
+// and not by the connection state.
+//
+// Oversized datagrams are reported as EMSGSIZE on most platforms, but as ENOBUFS by the BSD based
+// ones (e.g. when writing to a local "unixgram" socket on macOS).
+func isRecordError(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || (runtime.GOOS != "linux" && errors.Is(err, syscall.ENOBUFS))
+}
@@ -0,0 +1,92 @@
+// names.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package syslog
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// FacilityNames maps the standard syslog facility names to their facility codes.
+var FacilityNames = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// SeverityNames maps the standard syslog severity names to their severity codes.
+var SeverityNames = map[string]syslog.Priority{
+	"emerg":   syslog.LOG_EMERG,
+	"alert":   syslog.LOG_ALERT,
+	"crit":    syslog.LOG_CRIT,
+	"err":     syslog.LOG_ERR,
+	"warning": syslog.LOG_WARNING,
+	"notice":  syslog.LOG_NOTICE,
+	"info":    syslog.LOG_INFO,
+	"debug":   syslog.LOG_DEBUG,
+}
+
+// ParseFacility gets the facility code for the given facility name (case-insensitive).
+func ParseFacility(name string) (syslog.Priority, error) {
+	facility, ok := FacilityNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility '%s'", name)
+	}
+	return facility, nil
+}
+
+// FacilityName gets the name of the facility contained in the given priority.
+//
+// Unknown facilities are returned as their numeric facility code.
+func FacilityName(priority syslog.Priority) string {
+	facility := priority & facilityMask
+	for name, code := range FacilityNames {
+		if code == facility {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", facility>>3)
+}
+
+// ParseSeverity gets the severity code for the given severity name (case-insensitive).
+func ParseSeverity(name string) (syslog.Priority, error) {
+	severity, ok := SeverityNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog severity '%s'", name)
+	}
+	return severity, nil
+}
+
+// SeverityName gets the name of the severity contained in the given priority.
+func SeverityName(priority syslog.Priority) string {
+	severity := priority & severityMask
+	for name, code := range SeverityNames {
+		if code == severity {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", severity)
+}
@@ -0,0 +1,164 @@
+// spool.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+)
+
+// SpoolFileName defines the name of the spool file created within the spool directory (see [WithSpool]).
+var SpoolFileName = "syslog.spool"
+
+// CorruptSpoolFileSuffix defines the suffix of the file (next to the spool file) receiving spooled records
+// that cannot be decoded (e.g. due to a truncated write).
+var CorruptSpoolFileSuffix = ".corrupt"
+
+type spoolRecord struct {
+	Level  zerolog.Level `json:"level"`
+	Record string        `json:"record"`
+}
+
+type spool struct {
+	path string
+	// pending is set as long as the spool file may contain records (initially set to
+	// drain records left over by a previous run)
+	pending bool
+	// errors and lastError track the failed spool file operations (reported separately
+	// from the syslog server failures)
+	errors    uint64
+	lastError error
+}
+
+func (s *spool) failure(err error) error {
+	s.errors++
+	s.lastError = err
+	return err
+}
+
+func (s *spool) append(level zerolog.Level, p []byte) error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return s.failure(fmt.Errorf("failed to open spool file '%s' (cause: %w)", s.path, err))
+	}
+	defer file.Close()
+	err = json.NewEncoder(file).Encode(&spoolRecord{Level: level, Record: string(p)})
+	if err != nil {
+		return s.failure(fmt.Errorf("failed to write spool file '%s' (cause: %w)", s.path, err))
+	}
+	s.pending = true
+	return nil
+}
+
+// drain replays the spooled records using the given write function and returns its error (if any).
+//
+// Errors accessing the spool file itself are not returned, but recorded as spool failures. Records
+// that cannot be decoded are moved aside (see [CorruptSpoolFileSuffix]), so they do not block the
+// delivery of the remaining records.
+func (s *spool) drain(write func(level zerolog.Level, p []byte) error) error {
+	if !s.pending {
+		return nil
+	}
+	records, err := s.read()
+	if err != nil {
+		s.failure(err)
+		return nil
+	}
+	if len(records) == 0 {
+		s.pending = false
+		return nil
+	}
+	for i, record := range records {
+		err = write(record.Level, []byte(record.Record))
+		if err != nil {
+			rewriteErr := s.rewrite(records[i:])
+			if rewriteErr != nil {
+				s.failure(rewriteErr)
+			}
+			return err
+		}
+	}
+	err = os.Remove(s.path)
+	if err != nil {
+		s.failure(fmt.Errorf("failed to remove spool file '%s' (cause: %w)", s.path, err))
+		return nil
+	}
+	s.pending = false
+	return nil
+}
+
+func (s *spool) read() ([]spoolRecord, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read spool file '%s' (cause: %w)", s.path, err)
+	}
+	var records []spoolRecord
+	var corrupt [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record spoolRecord
+		err = json.Unmarshal(line, &record)
+		if err != nil {
+			corrupt = append(corrupt, line)
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(corrupt) > 0 {
+		s.failure(s.moveAside(corrupt))
+	}
+	return records, nil
+}
+
+// moveAside appends the given undecodable spool lines to the corrupt spool file.
+func (s *spool) moveAside(lines [][]byte) error {
+	path := s.path + CorruptSpoolFileSuffix
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err == nil {
+		_, err = file.Write(append(bytes.Join(lines, []byte("\n")), '\n'))
+		err = errors.Join(err, file.Close())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move %d corrupt record(s) from spool file '%s' to '%s' (cause: %w)", len(lines), s.path, path, err)
+	}
+	return fmt.Errorf("moved %d corrupt record(s) from spool file '%s' to '%s'", len(lines), s.path, path)
+}
+
+func (s *spool) rewrite(records []spoolRecord) error {
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file (cause: %w)", err)
+	}
+	encoder := json.NewEncoder(file)
+	for i := range records {
+		err = encoder.Encode(&records[i])
+		if err != nil {
+			break
+		}
+	}
+	err = errors.Join(err, file.Close())
+	if err == nil {
+		err = os.Rename(file.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to rewrite spool file '%s' (cause: %w)", s.path, err)
+	}
+	return nil
+}
@@ -9,20 +9,318 @@
 package syslog
 
 import (
+	"fmt"
 	"io"
+	"io/fs"
 	"log/syslog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalSocketPaths defines the socket paths probed for the "unix" and "unixgram" networks if no address is set.
+//
+// The paths are probed in order when the writer is created. If none of them is a socket, the first one is used.
+var LocalSocketPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Option customizes the syslog writer created by [NewWriter].
+type Option func(*writerOptions)
+
+type writerOptions struct {
+	tag            string
+	cee            bool
+	severityMapper SeverityMapper
+	facility       syslog.Priority
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+	retryAttempts  int
+	retryTime      time.Duration
+	bufferSize     int
+	failover       []string
+	failback       time.Duration
+	roundRobin     bool
+	spoolDir       string
+	maxMessageSize int
+}
 
-	"github.com/rs/zerolog"
+// WithTag sets the tag to use for the syslog messages (defaults to the program name).
+func WithTag(tag string) Option {
+	return func(options *writerOptions) {
+		options.tag = tag
+	}
+}
+
+// WithCEE sets whether messages are prefixed with the MITRE CEE cookie ("@cee:") for JSON syslog processing.
+func WithCEE(cee bool) Option {
+	return func(options *writerOptions) {
+		options.cee = cee
+	}
+}
+
+// WithSeverityMapper sets the function mapping log levels to syslog severities.
+func WithSeverityMapper(severityMapper SeverityMapper) Option {
+	return func(options *writerOptions) {
+		options.severityMapper = severityMapper
+	}
+}
+
+// WithFacility sets the facility to use for the syslog messages (defaults to [log/syslog.LOG_USER]).
+//
+// The facility can be overridden for individual log records by adding a field named [FacilityFieldName].
+func WithFacility(facility syslog.Priority) Option {
+	return func(options *writerOptions) {
+		options.facility = facility & facilityMask
+	}
+}
+
+// WithBackoff sets the delays used to retry a failed syslog connection.
+//
+// After a failed write, further writes are dropped (reporting [ErrUnavailable]) or buffered (see
+// [WithBuffer]) until the retry delay has passed. The delay starts with the given initial delay and
+// is doubled (with jitter) after every consecutive failure up to the given maximum delay. Failures caused by the record itself
+// (e.g. an oversized message) do not trigger a retry delay. See [WithRetryLimit] for limiting the number of retries.
+func WithBackoff(initial time.Duration, max time.Duration) Option {
+	return func(options *writerOptions) {
+		options.backoffInitial = initial
+		options.backoffMax = max
+	}
+}
+
+// WithRetryLimit sets the retry budget for a failed syslog connection.
+//
+// Once the given number of consecutive connection attempts has failed or the syslog server has been
+// unavailable for the given time, the writer fails permanently: buffered records are spooled (see
+// [WithSpool]) or dropped and all further records are handled the same way (reporting [ErrPermanentFailure]).
+// The same happens immediately if the connection fails for a reason retrying cannot resolve (e.g. an
+// unknown network or host). A limit of 0 disables the corresponding check (the default).
+func WithRetryLimit(attempts int, duration time.Duration) Option {
+	return func(options *writerOptions) {
+		options.retryAttempts = attempts
+		options.retryTime = duration
+	}
+}
+
+// WithBuffer sets the maximum number of log records buffered while the syslog server is unavailable.
+//
+// Buffered records are replayed as soon as the syslog server is available again. If the buffer is
+// full, the oldest buffered record is dropped. A buffer size of 0 disables buffering.
+func WithBuffer(bufferSize int) Option {
+	return func(options *writerOptions) {
+		options.bufferSize = bufferSize
+	}
+}
+
+// WithSpool sets the directory used to spool log records that cannot be buffered in memory (see [WithBuffer]).
+//
+// Records that would otherwise be dropped while the syslog server is unavailable are appended to the
+// spool file [SpoolFileName] within the given directory. The spool file is drained as soon as the syslog
+// server is available again. Spooled records that cannot be decoded are moved aside (see [CorruptSpoolFileSuffix]).
+// Failures accessing the spool file are reported separately from the syslog server failures (see [Stats]).
+// An empty directory disables spooling.
+func WithSpool(dir string) Option {
+	return func(options *writerOptions) {
+		options.spoolDir = dir
+	}
+}
+
+// WithFailover sets additional addresses to connect to if the primary syslog address is unavailable.
+//
+// The addresses are tried in order whenever a connection is established. The health of each address
+// is tracked: an address that failed is considered down and skipped until it is re-probed (see
+// [WithFailback]), or until all other addresses are down as well. While connected to a failover address,
+// the writer fails back to the primary address as soon as the latter is available again. A record that
+// cannot be sent due to a connection failure is re-sent via the next available address.
+func WithFailover(addresses ...string) Option {
+	return func(options *writerOptions) {
+		options.failover = addresses
+	}
+}
+
+// WithRoundRobin enables load balancing across the primary and failover addresses (see [WithFailover]).
+//
+// Instead of preferring the primary address, consecutive records are sent to the addresses in turn,
+// skipping the ones currently considered down.
+func WithRoundRobin(roundRobin bool) Option {
+	return func(options *writerOptions) {
+		options.roundRobin = roundRobin
+	}
+}
+
+// DefaultFailbackInterval defines the default interval for re-probing the primary syslog address.
+const DefaultFailbackInterval = 1 * time.Minute
+
+// WithFailback sets the interval after which an address considered down (see [WithFailover]) is re-probed.
+// While connected to a failover address, this is the interval for failing back to the primary address. An
+// interval of 0 disables periodic re-probing, in which case addresses considered down are only re-probed
+// after all other addresses have failed.
+func WithFailback(interval time.Duration) Option {
+	return func(options *writerOptions) {
+		options.failback = interval
+	}
+}
+
+const (
+	// DefaultMaxDatagramMessageSize defines the default maximum message size for datagram based networks ("udp" and "unixgram").
+	DefaultMaxDatagramMessageSize = 2048
+	// DefaultMaxStreamMessageSize defines the default maximum message size for stream based networks ("tcp" and "unix").
+	DefaultMaxStreamMessageSize = 8192
 )
 
+// WithMaxMessageSize sets the maximum size of the syslog messages (excluding the syslog header).
+//
+// Longer messages are truncated at an UTF-8 character boundary. Be aware that a truncated message is no
+// longer valid JSON (see [github.com/tdrn-org/go-log.NewSizeLimitWriter] for JSON aware truncation).
+// A maximum size of 0 disables truncation. The default depends on the network used (see
+// [DefaultMaxDatagramMessageSize] and [DefaultMaxStreamMessageSize]).
+func WithMaxMessageSize(size int) Option {
+	return func(options *writerOptions) {
+		options.maxMessageSize = size
+	}
+}
+
+// NewWriter creates a new [io.Writer] for syslog logging.
+//
+// The network and address parameters are passed to [log/syslog.Dial]. Besides the IP based networks,
+// the "unix" and "unixgram" networks are supported for logging to a local socket. An empty network
+// connects to the local syslog server by probing the platform's standard sockets. The same applies
+// to the "unix" and "unixgram" networks if no address is set (see [LocalSocketPaths]). Be aware
+// that messages sent via "unixgram" are subject to the system's maximum datagram size and may be
+// rejected if they exceed it (see [WithMaxMessageSize]).
+//
+// The connection is established lazily on the first write and re-established after a failure (see
+// [WithBackoff]). The returned writer implements [StatsProvider] to report its delivery statistics.
+func NewWriter(network string, address string, options ...Option) io.Writer {
+	writerOptions := &writerOptions{
+		severityMapper: DefaultSeverityMapper,
+		facility:       syslog.LOG_USER,
+		backoffInitial: DefaultBackoffInitial,
+		backoffMax:     DefaultBackoffMax,
+		failback:       DefaultFailbackInterval,
+		maxMessageSize: defaultMaxMessageSize(network),
+	}
+	for _, option := range options {
+		option(writerOptions)
+	}
+	if (network == "unix" || network == "unixgram") && address == "" {
+		address = localSocketPath()
+	}
+	dial := func(address string, facility syslog.Priority) (*syslog.Writer, error) {
+		writer, err := syslog.Dial(network, address, syslog.LOG_INFO|facility, writerOptions.tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog '%s:%s' (cause: %w)", network, address, err)
+		}
+		return writer, nil
+	}
+	targets := []target{{address: address}}
+	for _, address := range writerOptions.failover {
+		targets = append(targets, target{address: address})
+	}
+	levelWriter := &levelWriter{
+		dial:           dial,
+		targets:        targets,
+		roundRobin:     writerOptions.roundRobin,
+		facility:       writerOptions.facility,
+		writers:        make(map[writerKey]*syslog.Writer),
+		severityMapper: writerOptions.severityMapper,
+		backoff: backoff{
+			initial:     writerOptions.backoffInitial,
+			max:         max(writerOptions.backoffInitial, writerOptions.backoffMax),
+			maxAttempts: writerOptions.retryAttempts,
+			maxTime:     writerOptions.retryTime,
+		},
+		failback:       writerOptions.failback,
+		bufferSize:     writerOptions.bufferSize,
+		maxMessageSize: writerOptions.maxMessageSize,
+	}
+	if writerOptions.cee {
+		levelWriter.prefix = ceePrefix
+	}
+	if writerOptions.spoolDir != "" {
+		levelWriter.spool = &spool{path: filepath.Join(writerOptions.spoolDir, SpoolFileName), pending: true}
+	}
+	return levelWriter
+}
+
+func defaultMaxMessageSize(network string) int {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return DefaultMaxStreamMessageSize
+	}
+	return DefaultMaxDatagramMessageSize
+}
+
+func localSocketPath() string {
+	for _, path := range LocalSocketPaths {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode()&fs.ModeSocket != 0 {
+			return path
+		}
+	}
+	return LocalSocketPaths[0]
+}
+
 type YAMLSyslogConfig struct {
-	EnabledOption bool `yaml:"enabled"`
-	CEEOption     bool `yaml:"cee"`
+	EnabledOption        bool     `yaml:"enabled"`
+	NetworkOption        string   `yaml:"network"`
+	AddressOption        string   `yaml:"address"`
+	TagOption            string   `yaml:"tag"`
+	FacilityOption       string   `yaml:"facility"`
+	CEEOption            bool     `yaml:"cee"`
+	BufferOption         int      `yaml:"buffer"`
+	FailoverOption       []string `yaml:"failover"`
+	FailbackOption       string   `yaml:"failback"`
+	RoundRobinOption     bool     `yaml:"roundRobin"`
+	RetryAttemptsOption  int      `yaml:"retryAttempts"`
+	RetryTimeOption      string   `yaml:"retryTime"`
+	SpoolOption          string   `yaml:"spool"`
+	MaxMessageSizeOption int      `yaml:"maxMessageSize"`
 }
 
 func (config *YAMLSyslogConfig) NewWriter() io.Writer {
-	if config.CEEOption {
-		return zerolog.SyslogCEEWriter(&syslog.Writer{})
+	return NewWriter(config.NetworkOption, config.AddressOption, config.options()...)
+}
+
+func (config *YAMLSyslogConfig) options() []Option {
+	options := []Option{
+		WithTag(config.TagOption),
+		WithFacility(config.facilityOption()),
+		WithCEE(config.CEEOption),
+		WithBuffer(config.BufferOption),
+		WithFailover(config.FailoverOption...),
+		WithFailback(config.failbackOption()),
+		WithRoundRobin(config.RoundRobinOption),
+		WithRetryLimit(config.RetryAttemptsOption, config.retryTimeOption()),
+		WithSpool(config.SpoolOption),
+	}
+	switch {
+	case config.MaxMessageSizeOption > 0:
+		options = append(options, WithMaxMessageSize(config.MaxMessageSizeOption))
+	case config.MaxMessageSizeOption < 0:
+		options = append(options, WithMaxMessageSize(0))
+	}
+	return options
+}
+
+func (config *YAMLSyslogConfig) failbackOption() time.Duration {
+	failback, err := time.ParseDuration(config.FailbackOption)
+	if err != nil || failback < 0 {
+		return DefaultFailbackInterval
+	}
+	return failback
+}
+
+func (config *YAMLSyslogConfig) retryTimeOption() time.Duration {
+	retryTime, err := time.ParseDuration(config.RetryTimeOption)
+	if err != nil || retryTime < 0 {
+		return 0
+	}
+	return retryTime
+}
+
+func (config *YAMLSyslogConfig) facilityOption() syslog.Priority {
+	facility, err := ParseFacility(config.FacilityOption)
+	if err != nil {
+		return syslog.LOG_USER
 	}
-	return zerolog.SyslogLevelWriter(&syslog.Writer{})
+	return facility
 }
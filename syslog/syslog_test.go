@@ -0,0 +1,364 @@
+// syslog_test.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package syslog_test
+
+import (
+	"io"
+	stdsyslog "log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/tdrn-org/go-log/syslog"
+)
+
+func TestUnixgram(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"))
+	_, err := writer.(zerolog.LevelWriter).WriteLevel(zerolog.WarnLevel, []byte(`{"message":"message"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `^<12>.* test\[\d+\]: \{"message":"message"\}\n$`, readMessage(t, conn))
+}
+
+func TestLocalSocket(t *testing.T) {
+	dir := t.TempDir()
+	regularFile := filepath.Join(dir, "regular")
+	require.NoError(t, os.WriteFile(regularFile, nil, 0600))
+	_, conn1 := listenUnixgramAt(t, filepath.Join(dir, "log1.sock"))
+	defer conn1.Close()
+	_, conn2 := listenUnixgramAt(t, filepath.Join(dir, "log2.sock"))
+	defer conn2.Close()
+	setLocalSocketPaths(t, filepath.Join(dir, "missing"), regularFile, filepath.Join(dir, "log1.sock"), filepath.Join(dir, "log2.sock"))
+	writer := syslog.NewWriter("unixgram", "", syslog.WithTag("test"))
+	_, err := writer.Write([]byte(`{"message":"message"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `: \{"message":"message"\}\n$`, readMessage(t, conn1))
+}
+
+func TestLocalSocketNotFound(t *testing.T) {
+	dir := t.TempDir()
+	setLocalSocketPaths(t, filepath.Join(dir, "log1.sock"), filepath.Join(dir, "log2.sock"))
+	writer := syslog.NewWriter("unixgram", "", syslog.WithTag("test"))
+	// Without any socket present, the first candidate is used once it shows up
+	_, conn := listenUnixgramAt(t, filepath.Join(dir, "log1.sock"))
+	defer conn.Close()
+	_, err := writer.Write([]byte(`{"message":"message"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `: \{"message":"message"\}\n$`, readMessage(t, conn))
+}
+
+func setLocalSocketPaths(t *testing.T, paths ...string) {
+	localSocketPaths := syslog.LocalSocketPaths
+	syslog.LocalSocketPaths = paths
+	t.Cleanup(func() {
+		syslog.LocalSocketPaths = localSocketPaths
+	})
+}
+
+func TestSeverityMapper(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	severityMapper := func(level zerolog.Level) stdsyslog.Priority {
+		if level == zerolog.TraceLevel {
+			return stdsyslog.LOG_NOTICE
+		}
+		return syslog.DefaultSeverityMapper(level)
+	}
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithSeverityMapper(severityMapper))
+	_, err := writer.(zerolog.LevelWriter).WriteLevel(zerolog.TraceLevel, []byte(`{"message":"message"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `^<13>`, readMessage(t, conn))
+}
+
+func listenUnixgram(t *testing.T) (string, net.Conn) {
+	return listenUnixgramAt(t, filepath.Join(t.TempDir(), "log.sock"))
+}
+
+func listenUnixgramAt(t *testing.T, address string) (string, net.Conn) {
+	_ = os.Remove(address)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: address, Net: "unixgram"})
+	require.NoError(t, err)
+	return address, conn
+}
+
+func readMessage(t *testing.T, conn net.Conn) string {
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	return string(buffer[:n])
+}
+
+func TestFacility(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithFacility(stdsyslog.LOG_LOCAL3))
+	_, err := writer.(zerolog.LevelWriter).WriteLevel(zerolog.InfoLevel, []byte(`{"message":"message"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `^<158>`, readMessage(t, conn))
+	_, err = writer.(zerolog.LevelWriter).WriteLevel(zerolog.InfoLevel, []byte(`{"syslog_facility":"authpriv","message":"message"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `^<86>`, readMessage(t, conn))
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithMaxMessageSize(15))
+	_, err := writer.Write([]byte(`{"message":"ääää"}` + "\n"))
+	require.NoError(t, err)
+	require.Regexp(t, `: \{"message":"ä\n$`, readMessage(t, conn))
+}
+
+func TestFailover(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	primary := filepath.Join(t.TempDir(), "missing.sock")
+	writer := syslog.NewWriter("unixgram", primary, syslog.WithTag("test"), syslog.WithFailover(address))
+	_, err := writer.Write([]byte(`{"message":"message"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message"`, readMessage(t, conn))
+}
+
+func TestRoundRobin(t *testing.T) {
+	address1, conn1 := listenUnixgram(t)
+	defer conn1.Close()
+	address2, conn2 := listenUnixgram(t)
+	defer conn2.Close()
+	writer := syslog.NewWriter("unixgram", address1, syslog.WithTag("test"), syslog.WithFailover(address2), syslog.WithRoundRobin(true))
+	for _, message := range []string{"message 1", "message 2", "message 3", "message 4"} {
+		_, err := writer.Write([]byte(`{"message":"` + message + `"}`))
+		require.NoError(t, err)
+	}
+	require.Regexp(t, `"message 1"`, readMessage(t, conn1))
+	require.Regexp(t, `"message 2"`, readMessage(t, conn2))
+	require.Regexp(t, `"message 3"`, readMessage(t, conn1))
+	require.Regexp(t, `"message 4"`, readMessage(t, conn2))
+}
+
+func TestTargetHealth(t *testing.T) {
+	address1, conn1 := listenUnixgram(t)
+	address2, conn2 := listenUnixgram(t)
+	defer conn2.Close()
+	writer := syslog.NewWriter("unixgram", address1, syslog.WithTag("test"), syslog.WithFailover(address2), syslog.WithRoundRobin(true), syslog.WithFailback(time.Hour))
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 1"`, readMessage(t, conn1))
+	conn1.Close()
+	for _, message := range []string{"message 2", "message 3", "message 4"} {
+		_, err := writer.Write([]byte(`{"message":"` + message + `"}`))
+		require.NoError(t, err)
+		require.Regexp(t, `"`+message+`"`, readMessage(t, conn2))
+	}
+	stats := writer.(syslog.StatsProvider).Stats()
+	require.Equal(t, []string{address1}, stats.Down)
+	require.Equal(t, uint64(0), stats.Failures)
+	require.Equal(t, uint64(4), stats.Sent)
+}
+
+func TestFailback(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	primary := filepath.Join(t.TempDir(), "primary.sock")
+	writer := syslog.NewWriter("unixgram", primary, syslog.WithTag("test"), syslog.WithFailover(address), syslog.WithFailback(time.Millisecond))
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 1"`, readMessage(t, conn))
+	_, primaryConn := listenUnixgramAt(t, primary)
+	defer primaryConn.Close()
+	time.Sleep(2 * time.Millisecond)
+	_, err = writer.Write([]byte(`{"message":"message 2"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 2"`, readMessage(t, primaryConn))
+}
+
+func TestBackoff(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithBackoff(time.Hour, time.Hour))
+	conn.Close()
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, syslog.ErrUnavailable)
+	_, err = writer.Write([]byte(`{"message":"message 2"}`))
+	require.ErrorIs(t, err, syslog.ErrUnavailable)
+}
+
+func TestRetryLimit(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "log.sock")
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithBackoff(time.Nanosecond, time.Nanosecond), syslog.WithRetryLimit(2, 0))
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, syslog.ErrPermanentFailure)
+	time.Sleep(time.Millisecond)
+	_, err = writer.Write([]byte(`{"message":"message 2"}`))
+	require.ErrorIs(t, err, syslog.ErrPermanentFailure)
+	_, conn := listenUnixgramAt(t, address)
+	defer conn.Close()
+	time.Sleep(time.Millisecond)
+	_, err = writer.Write([]byte(`{"message":"message 3"}`))
+	require.ErrorIs(t, err, syslog.ErrPermanentFailure)
+	require.Equal(t, uint64(3), writer.(syslog.StatsProvider).Stats().Dropped)
+}
+
+func TestPermanentFailure(t *testing.T) {
+	writer := syslog.NewWriter("invalid", "localhost:514", syslog.WithTag("test"))
+	_, err := writer.Write([]byte(`{"message":"message"}`))
+	require.ErrorIs(t, err, syslog.ErrPermanentFailure)
+}
+
+func TestStats(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithBackoff(time.Hour, time.Hour))
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.NoError(t, err)
+	conn.Close()
+	_, err = writer.Write([]byte(`{"message":"message 2"}`))
+	require.Error(t, err)
+	_, err = writer.Write([]byte(`{"message":"message 3"}`))
+	require.ErrorIs(t, err, syslog.ErrUnavailable)
+	stats := writer.(syslog.StatsProvider).Stats()
+	require.Equal(t, uint64(1), stats.Sent)
+	require.Equal(t, uint64(len(`{"message":"message 1"}`)), stats.Bytes)
+	require.Equal(t, uint64(1), stats.Failures)
+	require.Equal(t, uint64(2), stats.Dropped)
+	require.Equal(t, 0, stats.Buffered)
+	require.Error(t, stats.LastError)
+}
+
+func TestBuffer(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithBackoff(time.Millisecond, time.Millisecond), syslog.WithBuffer(1))
+	conn.Close()
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte(`{"message":"message 2"}`))
+	require.NoError(t, err)
+	_, conn = listenUnixgramAt(t, address)
+	defer conn.Close()
+	time.Sleep(2 * time.Millisecond)
+	_, err = writer.Write([]byte(`{"message":"message 3"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 2"`, readMessage(t, conn))
+	require.Regexp(t, `"message 3"`, readMessage(t, conn))
+}
+
+func TestLazyConnect(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "log.sock")
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithBackoff(time.Millisecond, time.Millisecond), syslog.WithBuffer(1))
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.NoError(t, err)
+	_, conn := listenUnixgramAt(t, address)
+	defer conn.Close()
+	time.Sleep(2 * time.Millisecond)
+	_, err = writer.Write([]byte(`{"message":"message 2"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 1"`, readMessage(t, conn))
+	require.Regexp(t, `"message 2"`, readMessage(t, conn))
+}
+
+func TestClose(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithBackoff(time.Hour, time.Hour), syslog.WithBuffer(1))
+	conn.Close()
+	_, err := writer.Write([]byte(`{"message":"message"}`))
+	require.NoError(t, err)
+	_, conn = listenUnixgramAt(t, address)
+	defer conn.Close()
+	require.NoError(t, writer.(io.Closer).Close())
+	require.Regexp(t, `"message"`, readMessage(t, conn))
+}
+
+func TestSpool(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	spoolDir := t.TempDir()
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithBackoff(time.Millisecond, time.Millisecond), syslog.WithBuffer(1), syslog.WithSpool(spoolDir))
+	conn.Close()
+	_, err := writer.Write([]byte(`{"message":"message 1"}`))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte(`{"message":"message 2"}`))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte(`{"message":"message 3"}`))
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(spoolDir, syslog.SpoolFileName))
+	_, conn = listenUnixgramAt(t, address)
+	defer conn.Close()
+	time.Sleep(2 * time.Millisecond)
+	_, err = writer.Write([]byte(`{"message":"message 4"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 1"`, readMessage(t, conn))
+	require.Regexp(t, `"message 2"`, readMessage(t, conn))
+	require.Regexp(t, `"message 3"`, readMessage(t, conn))
+	require.Regexp(t, `"message 4"`, readMessage(t, conn))
+	require.NoFileExists(t, filepath.Join(spoolDir, syslog.SpoolFileName))
+}
+
+func TestSpoolLeftOver(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	spoolDir := t.TempDir()
+	spoolFile := filepath.Join(spoolDir, syslog.SpoolFileName)
+	require.NoError(t, os.WriteFile(spoolFile, []byte(`{"level":"info","record":"{\"message\":\"message 1\"}"}`+"\n"), 0600))
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithSpool(spoolDir))
+	_, err := writer.Write([]byte(`{"message":"message 2"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 1"`, readMessage(t, conn))
+	require.Regexp(t, `"message 2"`, readMessage(t, conn))
+	require.NoFileExists(t, spoolFile)
+	// As long as nothing is spooled, the spool file is not touched anymore
+	require.NoError(t, os.WriteFile(spoolFile, []byte(`{"level":"info","record":"{\"message\":\"message 3\"}"}`+"\n"), 0600))
+	_, err = writer.Write([]byte(`{"message":"message 4"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 4"`, readMessage(t, conn))
+	require.FileExists(t, spoolFile)
+}
+
+func TestSpoolCorrupt(t *testing.T) {
+	address, conn := listenUnixgram(t)
+	defer conn.Close()
+	spoolDir := t.TempDir()
+	spoolFile := filepath.Join(spoolDir, syslog.SpoolFileName)
+	spool := `{"level":"info","record":"{\"message\":\"message 1\"}"}` + "\n" +
+		`garbage` + "\n" +
+		`{"level":"info","record":"{\"message\":\"message 2\"}"}` + "\n" +
+		`{"level":"info","record":"{\"mess`
+	require.NoError(t, os.WriteFile(spoolFile, []byte(spool), 0600))
+	writer := syslog.NewWriter("unixgram", address, syslog.WithTag("test"), syslog.WithSpool(spoolDir))
+	_, err := writer.Write([]byte(`{"message":"message 3"}`))
+	require.NoError(t, err)
+	require.Regexp(t, `"message 1"`, readMessage(t, conn))
+	require.Regexp(t, `"message 2"`, readMessage(t, conn))
+	require.Regexp(t, `"message 3"`, readMessage(t, conn))
+	require.NoFileExists(t, spoolFile)
+	corrupt, err := os.ReadFile(spoolFile + syslog.CorruptSpoolFileSuffix)
+	require.NoError(t, err)
+	require.Equal(t, "garbage\n"+`{"level":"info","record":"{\"mess`+"\n", string(corrupt))
+	stats := writer.(syslog.StatsProvider).Stats()
+	require.Equal(t, uint64(1), stats.SpoolErrors)
+	require.Error(t, stats.LastSpoolError)
+	require.Equal(t, uint64(0), stats.Failures)
+	require.NoError(t, stats.LastError)
+}
+
+func TestNames(t *testing.T) {
+	facility, err := syslog.ParseFacility("Local3")
+	require.NoError(t, err)
+	require.Equal(t, stdsyslog.LOG_LOCAL3, facility)
+	require.Equal(t, "local3", syslog.FacilityName(stdsyslog.LOG_LOCAL3|stdsyslog.LOG_ERR))
+	_, err = syslog.ParseFacility("unknown")
+	require.Error(t, err)
+	severity, err := syslog.ParseSeverity("WARNING")
+	require.NoError(t, err)
+	require.Equal(t, stdsyslog.LOG_WARNING, severity)
+	require.Equal(t, "warning", syslog.SeverityName(stdsyslog.LOG_LOCAL3|stdsyslog.LOG_WARNING))
+	_, err = syslog.ParseSeverity("unknown")
+	require.Error(t, err)
+}
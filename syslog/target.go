@@ -0,0 +1,40 @@
+// target.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package syslog
+
+import (
+	"log/syslog"
+	"time"
+)
+
+// target tracks the health of a single syslog address.
+type target struct {
+	address string
+	down    bool
+	retryAt time.Time
+}
+
+// healthy checks whether the target is up or due to be re-probed. Targets that are down are only re-probed
+// if a re-probe interval is set (see [WithFailback]).
+func (t *target) healthy(now time.Time, interval time.Duration) bool {
+	return !t.down || (interval > 0 && !now.Before(t.retryAt))
+}
+
+func (t *target) success() {
+	t.down = false
+}
+
+func (t *target) failure(now time.Time, interval time.Duration) {
+	t.down = true
+	t.retryAt = now.Add(interval)
+}
+
+type writerKey struct {
+	facility syslog.Priority
+	target   int
+}
@@ -0,0 +1,429 @@
+// writer.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/syslog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// See http://cee.mitre.org/language/1.0-beta1/clt.html#syslog
+const ceePrefix = "@cee:"
+
+// SeverityMapper maps log levels to syslog severities.
+type SeverityMapper func(level zerolog.Level) syslog.Priority
+
+// DefaultSeverityMapper maps the standard log levels to their corresponding syslog severities.
+// Levels below debug are mapped to debug, unknown levels above panic are mapped to notice.
+func DefaultSeverityMapper(level zerolog.Level) syslog.Priority {
+	switch level {
+	case zerolog.DebugLevel:
+		return syslog.LOG_DEBUG
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		return syslog.LOG_INFO
+	case zerolog.WarnLevel:
+		return syslog.LOG_WARNING
+	case zerolog.ErrorLevel:
+		return syslog.LOG_ERR
+	case zerolog.FatalLevel:
+		return syslog.LOG_EMERG
+	case zerolog.PanicLevel:
+		return syslog.LOG_CRIT
+	}
+	if level < zerolog.DebugLevel {
+		return syslog.LOG_DEBUG
+	}
+	return syslog.LOG_NOTICE
+}
+
+// FacilityFieldName defines the name of the field used to override the syslog facility of a single log record.
+//
+// The field value is either a facility name (e.g. "authpriv") or a facility code.
+var FacilityFieldName = "syslog_facility"
+
+const severityMask = 0x07
+const facilityMask = 0xf8
+
+// Stats contains the delivery statistics of a syslog writer.
+type Stats struct {
+	// Sent is the number of log records sent to the syslog server.
+	Sent uint64
+	// Bytes is the number of bytes sent to the syslog server (excluding the syslog header).
+	Bytes uint64
+	// Failures is the number of failed syslog connections.
+	Failures uint64
+	// Dropped is the number of log records dropped (neither sent, buffered nor spooled).
+	Dropped uint64
+	// Buffered is the number of log records currently buffered in memory.
+	Buffered int
+	// SpoolErrors is the number of failed spool file operations (see [WithSpool]), including the ones
+	// caused by corrupt spool records.
+	SpoolErrors uint64
+	// LastSpoolError is the last error that occurred while accessing the spool file (if any).
+	LastSpoolError error
+	// Down contains the syslog addresses currently considered unavailable.
+	Down []string
+	// LastError is the last error that occurred while sending a log record (if any).
+	LastError error
+}
+
+// StatsProvider is implemented by the writers created by [NewWriter] to report their delivery statistics.
+type StatsProvider interface {
+	// Stats gets the current delivery statistics.
+	Stats() Stats
+}
+
+type levelWriter struct {
+	mutex          sync.Mutex
+	dial           func(address string, facility syslog.Priority) (*syslog.Writer, error)
+	targets        []target
+	roundRobin     bool
+	next           int
+	facility       syslog.Priority
+	writers        map[writerKey]*syslog.Writer
+	severityMapper SeverityMapper
+	prefix         string
+	backoff        backoff
+	failback       time.Duration
+	bufferSize     int
+	buffer         []bufferedRecord
+	spool          *spool
+	maxMessageSize int
+	failed         error
+	stats          Stats
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w *levelWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.failed != nil {
+		return w.discard(level, p, w.failed)
+	}
+	now := time.Now()
+	if !w.backoff.ready(now) {
+		return w.enqueue(level, p, ErrUnavailable)
+	}
+	err := w.replay()
+	if err != nil {
+		return w.fail(now, level, p, err)
+	}
+	err = w.writeLevel(level, p)
+	switch {
+	case err == nil:
+		w.backoff.success()
+	case isRecordError(err):
+		w.stats.Dropped++
+		w.stats.LastError = err
+		return 0, err
+	default:
+		return w.fail(now, level, p, err)
+	}
+	return len(p), nil
+}
+
+// fail handles a failed write of the given record by retrying it later or, once
+// the retry budget is exhausted, by giving up on the syslog server.
+func (w *levelWriter) fail(now time.Time, level zerolog.Level, p []byte, err error) (int, error) {
+	w.stats.LastError = err
+	w.failure(now)
+	permanent := errors.Is(err, ErrPermanentFailure)
+	if !permanent && !w.backoff.exhausted(now) {
+		return w.enqueue(level, p, err)
+	}
+	if !permanent {
+		err = fmt.Errorf("%w (cause: %w)", ErrPermanentFailure, err)
+	}
+	w.failed = err
+	w.stats.LastError = err
+	for len(w.buffer) > 0 {
+		w.evict()
+	}
+	w.buffer = nil
+	return w.discard(level, p, err)
+}
+
+// failure records a connection failure and drops the current connections, so that the next
+// retry re-establishes them (and thereby re-evaluates the failover addresses).
+func (w *levelWriter) failure(now time.Time) {
+	w.stats.Failures++
+	w.backoff.failure(now)
+	w.closeWriters()
+}
+
+type bufferedRecord struct {
+	level zerolog.Level
+	p     []byte
+}
+
+func (w *levelWriter) enqueue(level zerolog.Level, p []byte, err error) (int, error) {
+	if w.bufferSize <= 0 {
+		return w.discard(level, p, err)
+	}
+	if len(w.buffer) >= w.bufferSize {
+		w.evict()
+	}
+	w.buffer = append(w.buffer, bufferedRecord{level: level, p: bytes.Clone(p)})
+	return len(p), nil
+}
+
+// discard spools the given record (if enabled) or drops it.
+func (w *levelWriter) discard(level zerolog.Level, p []byte, err error) (int, error) {
+	if w.spool != nil && w.spool.append(level, p) == nil {
+		return len(p), nil
+	}
+	w.stats.Dropped++
+	return 0, err
+}
+
+func (w *levelWriter) evict() {
+	if w.spool == nil || w.spool.append(w.buffer[0].level, w.buffer[0].p) != nil {
+		w.stats.Dropped++
+	}
+	w.buffer[0] = bufferedRecord{}
+	w.buffer = w.buffer[1:]
+}
+
+func (w *levelWriter) replay() error {
+	if w.spool != nil {
+		err := w.spool.drain(w.replayLevel)
+		if err != nil {
+			return err
+		}
+	}
+	for len(w.buffer) > 0 {
+		err := w.replayLevel(w.buffer[0].level, w.buffer[0].p)
+		if err != nil {
+			return err
+		}
+		w.buffer[0] = bufferedRecord{}
+		w.buffer = w.buffer[1:]
+	}
+	w.buffer = nil
+	return nil
+}
+
+func (w *levelWriter) replayLevel(level zerolog.Level, p []byte) error {
+	err := w.writeLevel(level, p)
+	if err != nil && isRecordError(err) {
+		w.stats.Dropped++
+		return nil
+	}
+	return err
+}
+
+// writeLevel sends a single record. If sending fails due to the connection state, the record is
+// re-sent via the next available target until all targets have been tried.
+func (w *levelWriter) writeLevel(level zerolog.Level, p []byte) error {
+	facility := w.recordFacility(p)
+	message := w.truncate(w.prefix + string(p))
+	severity := w.severityMapper(level) & severityMask
+	for attempt := 1; ; attempt++ {
+		index, writer, err := w.connect(facility)
+		if err != nil {
+			return err
+		}
+		err = send(writer, severity, message)
+		if err == nil {
+			w.stats.Sent++
+			w.stats.Bytes += uint64(len(p))
+			return nil
+		}
+		if isRecordError(err) {
+			return err
+		}
+		w.targetFailure(index, time.Now())
+		if attempt >= len(w.targets) {
+			return err
+		}
+	}
+}
+
+func send(writer *syslog.Writer, severity syslog.Priority, message string) error {
+	switch severity {
+	case syslog.LOG_EMERG:
+		return writer.Emerg(message)
+	case syslog.LOG_ALERT:
+		return writer.Alert(message)
+	case syslog.LOG_CRIT:
+		return writer.Crit(message)
+	case syslog.LOG_ERR:
+		return writer.Err(message)
+	case syslog.LOG_WARNING:
+		return writer.Warning(message)
+	case syslog.LOG_NOTICE:
+		return writer.Notice(message)
+	case syslog.LOG_INFO:
+		return writer.Info(message)
+	}
+	return writer.Debug(message)
+}
+
+func (w *levelWriter) truncate(message string) string {
+	message = strings.TrimSuffix(message, "\n")
+	if w.maxMessageSize <= 0 || len(message) <= w.maxMessageSize {
+		return message
+	}
+	cut := w.maxMessageSize
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut]
+}
+
+func (w *levelWriter) recordFacility(p []byte) syslog.Priority {
+	if !bytes.Contains(p, []byte(`"`+FacilityFieldName+`"`)) {
+		return w.facility
+	}
+	var record map[string]interface{}
+	err := json.Unmarshal(p, &record)
+	if err != nil {
+		return w.facility
+	}
+	switch facility := record[FacilityFieldName].(type) {
+	case string:
+		namedFacility, err := ParseFacility(facility)
+		if err == nil {
+			return namedFacility
+		}
+	case float64:
+		if facility >= 0 && facility <= float64(syslog.LOG_LOCAL7>>3) {
+			return syslog.Priority(facility) << 3
+		}
+	}
+	return w.facility
+}
+
+// connect gets a connection for the given facility.
+//
+// Healthy targets are tried first, either in order (failover) or starting with the next target in turn
+// (round-robin). Only if none of them is available, the targets currently considered down are tried.
+func (w *levelWriter) connect(facility syslog.Priority) (int, *syslog.Writer, error) {
+	now := time.Now()
+	start := 0
+	if w.roundRobin {
+		start = w.next
+		w.next = (w.next + 1) % len(w.targets)
+	}
+	var errs []error
+	permanent := true
+	var tried []bool
+	for _, healthy := range []bool{true, false} {
+		for i := range w.targets {
+			index := (start + i) % len(w.targets)
+			if w.targets[index].healthy(now, w.failback) != healthy || (tried != nil && tried[index]) {
+				continue
+			}
+			writer, err := w.targetWriter(facility, index)
+			if err == nil {
+				return index, writer, nil
+			}
+			w.targetFailure(index, now)
+			if tried == nil {
+				tried = make([]bool, len(w.targets))
+			}
+			tried[index] = true
+			permanent = permanent && isPermanentError(err)
+			errs = append(errs, err)
+		}
+	}
+	if permanent {
+		return 0, nil, fmt.Errorf("%w (cause: %w)", ErrPermanentFailure, errors.Join(errs...))
+	}
+	return 0, nil, errors.Join(errs...)
+}
+
+func (w *levelWriter) targetWriter(facility syslog.Priority, index int) (*syslog.Writer, error) {
+	key := writerKey{facility: facility, target: index}
+	writer, ok := w.writers[key]
+	if ok {
+		return writer, nil
+	}
+	writer, err := w.dial(w.targets[index].address, facility)
+	if err != nil {
+		return nil, err
+	}
+	w.targets[index].success()
+	if !w.roundRobin {
+		// Fail back from any lower priority target
+		for key, failoverWriter := range w.writers {
+			if key.facility == facility && key.target > index {
+				failoverWriter.Close()
+				delete(w.writers, key)
+			}
+		}
+	}
+	w.writers[key] = writer
+	return writer, nil
+}
+
+// targetFailure marks the given target as down and drops its connections.
+func (w *levelWriter) targetFailure(index int, now time.Time) {
+	w.targets[index].failure(now, w.failback)
+	for key, writer := range w.writers {
+		if key.target == index {
+			writer.Close()
+			delete(w.writers, key)
+		}
+	}
+}
+
+func (w *levelWriter) Stats() Stats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	stats := w.stats
+	stats.Buffered = len(w.buffer)
+	if w.spool != nil {
+		stats.SpoolErrors = w.spool.errors
+		stats.LastSpoolError = w.spool.lastError
+	}
+	for _, target := range w.targets {
+		if target.down {
+			stats.Down = append(stats.Down, target.address)
+		}
+	}
+	return stats
+}
+
+// Close flushes any buffered log records and closes the syslog connections.
+//
+// Buffered log records that cannot be flushed are spooled (if enabled) or dropped.
+func (w *levelWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var err error
+	if w.failed == nil {
+		err = w.replay()
+	}
+	for len(w.buffer) > 0 {
+		w.evict()
+	}
+	w.buffer = nil
+	return errors.Join(err, w.closeWriters())
+}
+
+func (w *levelWriter) closeWriters() error {
+	var errs []error
+	for facility, writer := range w.writers {
+		errs = append(errs, writer.Close())
+		delete(w.writers, facility)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,348 @@
+// writer.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// NewLevelWriter creates a new [io.WriteCloser] logging every written line as a separate log record.
+//
+// The level of each line is detected by examining the line for typical level markers (e.g. "ERROR:",
+// "[WARN]" or "level=info"). Lines without detectable level are logged using the given default level.
+// The returned writer is suitable for capturing the output of a child process (e.g. via [os/exec.Cmd.Stdout]).
+// Closing the writer logs any pending incomplete line.
+func NewLevelWriter(logger *zerolog.Logger, level zerolog.Level) io.WriteCloser {
+	return &levelWriter{logger: logger, level: level}
+}
+
+type levelWriter struct {
+	mutex  sync.Mutex
+	logger *zerolog.Logger
+	level  zerolog.Level
+	buffer bytes.Buffer
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buffer.Write(p)
+	for {
+		lineEnd := bytes.IndexByte(w.buffer.Bytes(), '\n')
+		if lineEnd < 0 {
+			break
+		}
+		w.logLine(string(w.buffer.Next(lineEnd + 1)))
+	}
+	return len(p), nil
+}
+
+func (w *levelWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.buffer.Len() > 0 {
+		w.logLine(w.buffer.String())
+		w.buffer.Reset()
+	}
+	return nil
+}
+
+func (w *levelWriter) logLine(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return
+	}
+	level, msg := detectLevel(line, w.level)
+	w.logger.WithLevel(level).Msg(msg)
+}
+
+// DefaultTruncateMarker defines the marker appended to truncated log messages.
+const DefaultTruncateMarker = "…"
+
+// NewSizeLimitWriter creates a new [io.Writer] limiting the size of the log records written to the given writer.
+//
+// Records exceeding the given limit are shortened by dropping fields (except the level, timestamp, caller and
+// message fields) one at a time, starting with the largest one. The error field is dropped last. If the record
+// still exceeds the limit, the message is truncated and the given marker is appended. The order and encoding
+// of the remaining fields are left untouched. A limit less than or equal to 0 disables the limit.
+func NewSizeLimitWriter(w io.Writer, limit int, marker string) io.Writer {
+	if limit <= 0 {
+		return w
+	}
+	return &sizeLimitWriter{w: w, limit: limit, marker: marker}
+}
+
+type sizeLimitWriter struct {
+	w      io.Writer
+	limit  int
+	marker string
+}
+
+func (w *sizeLimitWriter) Write(p []byte) (int, error) {
+	_, err := w.w.Write(w.limitRecord(p))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *sizeLimitWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	levelWriter, ok := w.w.(zerolog.LevelWriter)
+	if !ok {
+		return w.Write(p)
+	}
+	_, err := levelWriter.WriteLevel(level, w.limitRecord(p))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// recordField is a single field of a JSON encoded log record, kept in its original encoding.
+type recordField struct {
+	key   string
+	value json.RawMessage
+}
+
+func (w *sizeLimitWriter) limitRecord(p []byte) []byte {
+	if len(p) <= w.limit {
+		return p
+	}
+	fields, err := decodeRecord(p)
+	if err != nil {
+		return p
+	}
+	limited := encodeRecord(fields)
+	for len(limited) > w.limit {
+		drop := droppableField(fields)
+		if drop < 0 {
+			break
+		}
+		fields = append(fields[:drop], fields[drop+1:]...)
+		limited = encodeRecord(fields)
+	}
+	messageIndex := slices.IndexFunc(fields, func(field recordField) bool { return field.key == zerolog.MessageFieldName })
+	if messageIndex < 0 {
+		return limited
+	}
+	var message string
+	err = json.Unmarshal(fields[messageIndex].value, &message)
+	if err != nil {
+		return limited
+	}
+	for len(limited) > w.limit && message != "" {
+		excess := len(limited) - w.limit + len(w.marker)
+		message = truncateUTF8(message, len(message)-excess)
+		fields[messageIndex].value = encodeString(message + w.marker)
+		limited = encodeRecord(fields)
+	}
+	return limited
+}
+
+// droppableField gets the index of the next field to drop (or -1 if there is none).
+func droppableField(fields []recordField) int {
+	drop := -1
+	for i, field := range fields {
+		switch field.key {
+		case zerolog.LevelFieldName, zerolog.TimestampFieldName, zerolog.CallerFieldName, zerolog.MessageFieldName:
+			continue
+		}
+		if drop < 0 || dropBefore(field, fields[drop]) {
+			drop = i
+		}
+	}
+	return drop
+}
+
+func dropBefore(field recordField, other recordField) bool {
+	if (field.key == zerolog.ErrorFieldName) != (other.key == zerolog.ErrorFieldName) {
+		return other.key == zerolog.ErrorFieldName
+	}
+	return len(field.value) > len(other.value)
+}
+
+func decodeRecord(p []byte) ([]recordField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, errors.New("unexpected record start")
+	}
+	var fields []recordField
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, recordField{key: key, value: value})
+	}
+	return fields, nil
+}
+
+func encodeRecord(fields []recordField) []byte {
+	return append(encodeObject(fields), '\n')
+}
+
+func encodeObject(fields []recordField) []byte {
+	encoded := []byte{'{'}
+	for i, field := range fields {
+		if i > 0 {
+			encoded = append(encoded, ',')
+		}
+		encoded = append(encoded, encodeString(field.key)...)
+		encoded = append(encoded, ':')
+		encoded = append(encoded, field.value...)
+	}
+	return append(encoded, '}')
+}
+
+func encodeString(s string) []byte {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return bytes.TrimSuffix(buffer.Bytes(), []byte{'\n'})
+}
+
+// NewSortedFieldsWriter creates a new [io.Writer] sorting the fields of the log records written to the given writer.
+//
+// The level, timestamp and caller fields are kept in front and the message field is moved to the end. The
+// remaining fields are sorted by key, with nested objects (groups) preceding plain fields. The fields of
+// nested objects are sorted the same way. This results in stable log lines (e.g. for golden file tests),
+// regardless of the order in which the fields were added.
+func NewSortedFieldsWriter(w io.Writer) io.Writer {
+	return &sortedFieldsWriter{w: w}
+}
+
+type sortedFieldsWriter struct {
+	w io.Writer
+}
+
+func (w *sortedFieldsWriter) Write(p []byte) (int, error) {
+	_, err := w.w.Write(sortRecord(p))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *sortedFieldsWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	levelWriter, ok := w.w.(zerolog.LevelWriter)
+	if !ok {
+		return w.Write(p)
+	}
+	_, err := levelWriter.WriteLevel(level, sortRecord(p))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func sortRecord(p []byte) []byte {
+	fields, err := decodeRecord(p)
+	if err != nil {
+		return p
+	}
+	sortFields(fields, true)
+	return encodeRecord(fields)
+}
+
+func sortFields(fields []recordField, record bool) {
+	for i := range fields {
+		if isObject(fields[i].value) {
+			nested, err := decodeRecord(fields[i].value)
+			if err == nil {
+				sortFields(nested, false)
+				fields[i].value = encodeObject(nested)
+			}
+		}
+	}
+	slices.SortStableFunc(fields, func(a recordField, b recordField) int {
+		rankA := fieldRank(a, record)
+		rankB := fieldRank(b, record)
+		if rankA != rankB {
+			return rankA - rankB
+		}
+		if rankA == rankGroup || rankA == rankField {
+			return strings.Compare(a.key, b.key)
+		}
+		return 0
+	})
+}
+
+const (
+	rankLevel = iota
+	rankTimestamp
+	rankCaller
+	rankGroup
+	rankField
+	rankMessage
+)
+
+func fieldRank(field recordField, record bool) int {
+	if record {
+		switch field.key {
+		case zerolog.LevelFieldName:
+			return rankLevel
+		case zerolog.TimestampFieldName:
+			return rankTimestamp
+		case zerolog.CallerFieldName:
+			return rankCaller
+		case zerolog.MessageFieldName:
+			return rankMessage
+		}
+	}
+	if isObject(field.value) {
+		return rankGroup
+	}
+	return rankField
+}
+
+func isObject(value json.RawMessage) bool {
+	return len(value) > 0 && value[0] == '{'
+}
+
+func truncateUTF8(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// SyncWriter wraps the given writer, so that concurrent writes to it are serialized.
+//
+// Every log record is passed to a writer with exactly one Write (or WriteLevel) call. This applies to the
+// writers provided by this module as well. Wrapping a writer shared by multiple loggers with SyncWriter
+// therefore ensures that log records are never interleaved, even if the writer itself is not safe for
+// concurrent use (e.g. a [bytes.Buffer]). Level information and Close are passed through.
+func SyncWriter(w io.Writer) io.Writer {
+	return zerolog.SyncWriter(w)
+}
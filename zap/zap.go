@@ -0,0 +1,97 @@
+// zap.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+// Package zap provides a [go.uber.org/zap/zapcore.Core] adapter for [github.com/rs/zerolog] loggers.
+package zap
+
+import (
+	"github.com/rs/zerolog"
+	"go.uber.org/zap/zapcore"
+)
+
+// LoggerFieldName defines the field name used to log the zap logger name.
+var LoggerFieldName = "logger"
+
+// NewCore creates a new [go.uber.org/zap/zapcore.Core] forwarding all log entries to the given logger.
+func NewCore(logger *zerolog.Logger) zapcore.Core {
+	return &core{logger: logger}
+}
+
+type core struct {
+	logger *zerolog.Logger
+}
+
+func (c *core) Enabled(level zapcore.Level) bool {
+	zerologLevel := zerologLevel(level)
+	return zerologLevel >= c.logger.GetLevel() && zerologLevel >= zerolog.GlobalLevel()
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	if len(fields) == 0 {
+		return c
+	}
+	logger := c.logger.With().Fields(encodeFields(fields)).Logger()
+	return &core{logger: &logger}
+}
+
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	event := c.logger.WithLevel(zerologLevel(entry.Level))
+	if entry.LoggerName != "" {
+		event = event.Str(LoggerFieldName, entry.LoggerName)
+	}
+	if entry.Caller.Defined {
+		event = event.Str(zerolog.CallerFieldName, entry.Caller.String())
+	}
+	if entry.Stack != "" {
+		event = event.Str(zerolog.ErrorStackFieldName, entry.Stack)
+	}
+	if len(fields) > 0 {
+		event = event.Fields(encodeFields(fields))
+	}
+	event.Msg(entry.Message)
+	return nil
+}
+
+func (c *core) Sync() error {
+	return nil
+}
+
+func encodeFields(fields []zapcore.Field) map[string]interface{} {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	return encoder.Fields
+}
+
+func zerologLevel(level zapcore.Level) zerolog.Level {
+	switch level {
+	case zapcore.DebugLevel:
+		return zerolog.DebugLevel
+	case zapcore.InfoLevel:
+		return zerolog.InfoLevel
+	case zapcore.WarnLevel:
+		return zerolog.WarnLevel
+	case zapcore.ErrorLevel:
+		return zerolog.ErrorLevel
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		return zerolog.PanicLevel
+	case zapcore.FatalLevel:
+		return zerolog.FatalLevel
+	}
+	if level < zapcore.DebugLevel {
+		return zerolog.TraceLevel
+	}
+	return zerolog.NoLevel
+}
@@ -0,0 +1,37 @@
+// zap_test.go
+//
+// Copyright (C) 2023-2024 Holger de Carne
+//
+// This software may be modified and distributed under the terms
+// of the MIT license. See the LICENSE file for details.
+
+package zap_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/tdrn-org/go-log"
+	logzap "github.com/tdrn-org/go-log/zap"
+	"go.uber.org/zap"
+)
+
+func TestCore(t *testing.T) {
+	_ = log.ResetRootLogger()
+	buffer := &bytes.Buffer{}
+	logger := zap.New(logzap.NewCore(log.NewLogger(buffer, false))).Named("test").With(zap.String("key1", "value1"))
+	logger.Info("info message")
+	require.Equal(t, 0, buffer.Len())
+	logger.Warn("warn message", zap.Int("key2", 2))
+	var event map[string]interface{}
+	err := json.Unmarshal(buffer.Bytes(), &event)
+	require.NoError(t, err)
+	require.Equal(t, zerolog.WarnLevel.String(), event[zerolog.LevelFieldName])
+	require.Equal(t, "warn message", event[zerolog.MessageFieldName])
+	require.Equal(t, "test", event[logzap.LoggerFieldName])
+	require.Equal(t, "value1", event["key1"])
+	require.Equal(t, float64(2), event["key2"])
+}